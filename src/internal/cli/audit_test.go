@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
+)
+
+// Distinctive credential values served by the fake endpoints. The audit fails
+// if any of them shows up in logs, errors or output not meant to carry them.
+const (
+	auditAccessKeyID     = "ASIAAUDITEXAMPLE0001"
+	auditSecretAccessKey = "auditSecretAccessKey/EXAMPLE+KEY"
+	auditSessionToken    = "auditSessionToken/EXAMPLE+TOKEN=="
+	auditSigninToken     = "auditSigninToken-EXAMPLE-TOKEN"
+	auditAccessToken     = "auditAccessToken-EXAMPLE"
+)
+
+var auditSecrets = []string{auditAccessKeyID, auditSecretAccessKey, auditSessionToken, auditSigninToken, auditAccessToken}
+
+// auditOutput collects everything a command logs or prints.
+type auditOutput struct {
+	logs   bytes.Buffer
+	stdout bytes.Buffer
+}
+
+// setupAudit writes a config with an SSO profile, caches an SSO token, fakes
+// the SSO portal and the federation endpoint, and routes logs (at trace
+// level), printFunc and the commands' output writers into the returned buffers.
+func setupAudit(t *testing.T) *auditOutput {
+	t.Helper()
+	writeAwsConfig(t, `[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+
+[profile dev]
+sso_session = corp
+sso_account_id = 111111111111
+sso_role_name = Admin
+region = eu-west-1
+`)
+	dir := isolateAwsPaths(t)
+	token := &sso.CachedToken{AccessToken: auditAccessToken, ExpiresAt: time.Now().Add(time.Hour).UTC().Format(sso.TimeFormat)}
+	if err := sso.SaveToken(filepath.Join(dir, "sso", "cache"), sso.TokenCacheKey("corp", ""), token); err != nil {
+		t.Fatalf("save token: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-amz-sso_bearer_token") != auditAccessToken {
+			w.Header().Set("x-amzn-ErrorType", "UnauthorizedException")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/federation/credentials":
+			_ = json.NewEncoder(w).Encode(map[string]any{"roleCredentials": map[string]any{
+				"accessKeyId":     auditAccessKeyID,
+				"secretAccessKey": auditSecretAccessKey,
+				"sessionToken":    auditSessionToken,
+				"expiration":      time.Now().Add(time.Hour).UnixMilli(),
+			}})
+		case "/assignment/accounts":
+			_ = json.NewEncoder(w).Encode(map[string]any{"accountList": []map[string]string{
+				{"accountId": "111111111111", "accountName": "Dev"},
+			}})
+		case "/assignment/roles":
+			_ = json.NewEncoder(w).Encode(map[string]any{"roleList": []map[string]string{
+				{"accountId": "111111111111", "roleName": "Admin"},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	origClient := newSSOClient
+	t.Cleanup(func() { newSSOClient = origClient })
+	newSSOClient = func(string) *sso.Client {
+		return &sso.Client{OIDCEndpoint: srv.URL, PortalEndpoint: srv.URL, HTTPClient: srv.Client()}
+	}
+
+	origGet := federationGet
+	t.Cleanup(func() { federationGet = origGet })
+	federationGet = func(raw string) ([]byte, error) {
+		return []byte(fmt.Sprintf(`{"SigninToken":%q}`, auditSigninToken)), nil
+	}
+
+	out := &auditOutput{}
+	logger := logrus.StandardLogger()
+	origLevel, origFormatter, origOut := logger.GetLevel(), logger.Formatter, logger.Out
+	origLevelFlag, origFormatFlag := logLevelFlag, logFormatFlag
+	t.Cleanup(func() {
+		logger.SetLevel(origLevel)
+		logger.SetFormatter(origFormatter)
+		logger.SetOutput(origOut)
+		logLevelFlag, logFormatFlag = origLevelFlag, origFormatFlag
+	})
+	logLevelFlag, logFormatFlag = "trace", ""
+	if err := configureLogging(consoleCmd); err != nil {
+		t.Fatalf("configureLogging: %v", err)
+	}
+	logger.SetOutput(&out.logs)
+
+	origPrint := printFunc
+	t.Cleanup(func() { printFunc = origPrint })
+	printFunc = func(format string, a ...any) (int, error) {
+		return fmt.Fprintf(&out.stdout, format, a...)
+	}
+	origExec := execCommand
+	t.Cleanup(func() { execCommand = origExec })
+	// No browser or child command can be started.
+	execCommand = func(string, ...string) *exec.Cmd { return exec.Command(filepath.Join(dir, "missing")) }
+
+	for _, w := range []*io.Writer{&summaryOutput, &statusOutput, &configureOutput, &exportOutput, &processOutput} {
+		orig := *w
+		t.Cleanup(func() { *w = orig })
+		*w = &out.stdout
+	}
+	return out
+}
+
+// assertNoSecrets fails if text contains any of the audit credentials, as
+// is or URL-encoded.
+func assertNoSecrets(t *testing.T, what, text string) {
+	t.Helper()
+	for _, secret := range auditSecrets {
+		if strings.Contains(text, secret) || strings.Contains(text, url.QueryEscape(secret)) {
+			t.Errorf("%s leaks %q:\n%s", what, secret, text)
+		}
+	}
+}
+
+// TestAudit_NoSecretsInOutput runs every command against fake endpoints and
+// checks that no credential reaches the logs, returned errors or output.
+// Output whose purpose is to hand over credentials (export, process and
+// console --print) is only checked for its logs.
+func TestAudit_NoSecretsInOutput(t *testing.T) {
+	commands := []struct {
+		name         string
+		run          func() error
+		carriesCreds bool
+		// fails is set for commands that cannot succeed without a real child process.
+		fails bool
+	}{
+		{name: "import", run: func() error { return importCreds("dev", importOptions{force: true}) }},
+		{name: "import batch", run: func() error { return importBatch([]string{"dev"}, importOptions{force: true, concurrency: 1}) }},
+		{name: "status table", run: func() error { return status("", "table") }},
+		{name: "status json", run: func() error { return status("dev", "json") }},
+		{name: "configure", run: func() error { return configure(configureOptions{session: "corp", dryRun: true}) }},
+		{name: "console", run: func() error { return console("dev", consoleOptions{}) }},
+		{name: "console --print", run: func() error { return console("dev", consoleOptions{print: true}) }, carriesCreds: true},
+		{name: "export", run: func() error { return exportCredsToOutput("dev", "sh") }, carriesCreds: true},
+		{name: "exec", run: func() error { return execWithCreds("dev", []string{"true"}) }, fails: true},
+		{name: "serve", run: func() error {
+			provider := &servedCredentials{manager: &awsCredentialsManager{profileName: "dev"}, window: serveMinRefreshWindow}
+			_, err := provider.get()
+			return err
+		}},
+		{name: "process", run: func() error { return processCreds("dev") }, carriesCreds: true},
+	}
+	for _, c := range commands {
+		t.Run(c.name, func(t *testing.T) {
+			out := setupAudit(t)
+			err := c.run()
+			if (err != nil) != c.fails {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil {
+				assertNoSecrets(t, "error", err.Error())
+			}
+			assertNoSecrets(t, "log output", out.logs.String())
+			if !c.carriesCreds {
+				assertNoSecrets(t, "output", out.stdout.String())
+			}
+		})
+	}
+}
+
+// TestAudit_ConsoleWithoutBrowser verifies that the fallback for a missing
+// browser does not print the sign-in URL, and that --print still does.
+func TestAudit_ConsoleWithoutBrowser(t *testing.T) {
+	out := setupAudit(t)
+	if err := console("dev", consoleOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.stdout.String(), "--print or --copy") {
+		t.Errorf("expected a hint to use --print or --copy, got %q", out.stdout.String())
+	}
+
+	out.stdout.Reset()
+	if err := console("dev", consoleOptions{print: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.stdout.String(), "SigninToken="+url.QueryEscape(auditSigninToken)) {
+		t.Errorf("expected --print to output the full sign-in URL, got %q", out.stdout.String())
+	}
+}
+
+// TestAudit_FederationErrors verifies that a failed sign-in token request does
+// not echo the federation URL, whose Session parameter holds the credentials.
+func TestAudit_FederationErrors(t *testing.T) {
+	out := setupAudit(t)
+	federationGet = func(raw string) ([]byte, error) {
+		_, err := http.Get("http://127.0.0.1:0/federation?" + strings.SplitN(raw, "?", 2)[1])
+		return nil, err
+	}
+	err := console("dev", consoleOptions{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	logrus.Error(err)
+	assertNoSecrets(t, "error", err.Error())
+	assertNoSecrets(t, "log output", out.logs.String())
+	if !strings.Contains(err.Error(), "requesting console sign-in token") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
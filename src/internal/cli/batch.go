@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/go-ini/ini"
+	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+	"github.com/witnsby/aws-sso-login/src/internal/profiles"
+	"github.com/witnsby/aws-sso-login/src/internal/redact"
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
+)
+
+// defaultImportConcurrency is the worker pool size for batch imports.
+const defaultImportConcurrency = 4
+
+// summaryOutput receives the batch import summary.
+var summaryOutput io.Writer = os.Stdout
+
+// batchResult is the outcome of importing a single profile in a batch.
+type batchResult struct {
+	profileName string
+	profile     *ini.Section
+	roleCred    *model.RoleCredential
+	// validUntil is set when the profile was skipped because its imported
+	// credentials are still valid.
+	validUntil string
+	err        error
+}
+
+// selectImportProfiles expands the explicit --profile names, --match glob
+// patterns and --all into a sorted, de-duplicated list of profile names.
+// Patterns and --all are evaluated against the SSO profiles in the config.
+func selectImportProfiles(names, patterns []string, all bool) ([]string, error) {
+	selected := map[string]bool{}
+	for _, name := range names {
+		selected[name] = true
+	}
+
+	if len(patterns) > 0 || all {
+		configPath, err := helper.GetAwsConfigPath()
+		if err != nil {
+			return nil, fmt.Errorf("could not determine AWS config path: %w", err)
+		}
+		ssoProfiles, err := profiles.ListSSOProfiles(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not list SSO profiles: %w", err)
+		}
+
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid --match pattern %q: %w", pattern, err)
+			}
+			matched := false
+			for _, p := range ssoProfiles {
+				if ok, _ := path.Match(pattern, p.Name); ok {
+					selected[p.Name] = true
+					matched = true
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("no SSO profiles match %q", pattern)
+			}
+		}
+		if all {
+			for _, p := range ssoProfiles {
+				selected[p.Name] = true
+			}
+		}
+	}
+
+	result := make([]string, 0, len(selected))
+	for name := range selected {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// importBatch imports credentials for several profiles at once.
+//
+// Role credentials are fetched concurrently by a bounded worker pool. Profiles
+// whose SSO token is missing or expired trigger at most one SSO login per
+// token (i.e. per sso-session or start URL) and are then retried. All
+// successfully fetched credentials are written to the credentials file in a
+// single locked update. Profiles whose imported credentials are still valid
+// are skipped unless opts.force is set. A per-profile summary is printed and
+// an error is returned if any profile failed.
+func importBatch(profileNames []string, opts importOptions) error {
+	results := make([]*batchResult, len(profileNames))
+	for i, name := range profileNames {
+		results[i] = &batchResult{profileName: name}
+		results[i].profile, results[i].err = retrieveProfile(name)
+		if results[i].err == nil && !opts.force {
+			results[i].validUntil, _ = importedCredsValid(name, results[i].profile)
+		}
+	}
+
+	fetchBatch(results, opts.concurrency)
+
+	// Log in once per SSO token and retry the affected profiles.
+	var retry []*batchResult
+	loggedIn := map[string]error{}
+	for _, r := range results {
+		if !errors.Is(r.err, errSSOLoginRequired) {
+			continue
+		}
+		key := sso.TokenCacheKey(r.profile.Key("sso_session").String(), r.profile.Key("sso_start_url").String())
+		loginErr, done := loggedIn[key]
+		if !done {
+			logrus.Infof("Credentials for profile [%s] not available, attempting SSO login...", r.profileName)
+			loginErr = runSSOLogin(r.profile)
+			loggedIn[key] = loginErr
+		}
+		if loginErr != nil {
+			r.err = fmt.Errorf("failed to perform SSO login for profile %s: %w", r.profileName, loginErr)
+			continue
+		}
+		r.err = nil
+		retry = append(retry, r)
+	}
+	fetchBatch(retry, opts.concurrency)
+
+	creds := map[string]*model.RoleCredential{}
+	for _, r := range results {
+		if r.err == nil && r.validUntil == "" {
+			creds[r.profileName] = r.roleCred
+		}
+	}
+
+	manager := awsCredentialsManager{backupCreds: opts.backup, pruneExpired: opts.prune}
+	if len(creds) > 0 || opts.prune {
+		if err := manager.updateCredsFile(creds); err != nil {
+			return err
+		}
+		if len(creds) > 0 {
+			logrus.Infof("Wrote credentials for %d profile(s) to %s", len(creds), manager.credentialsPath)
+		}
+	}
+
+	failed := printBatchSummary(summaryOutput, results)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d profiles failed to import", failed, len(results))
+	}
+	return nil
+}
+
+// fetchBatch fills roleCred (or err) for every result that has a profile and
+// no error yet, using at most concurrency parallel workers.
+//
+// The source_profile of each chained profile is fetched once beforehand, much
+// as importBatch logs in once per SSO token, so that chained profiles sharing
+// a source find its credentials cached instead of all refreshing them at once.
+// Errors fetching a source are left for its chained profiles to report.
+func fetchBatch(results []*batchResult, concurrency int) {
+	if concurrency < 1 {
+		concurrency = defaultImportConcurrency
+	}
+	runFetchPool(chainSources(results), concurrency)
+	runFetchPool(results, concurrency)
+}
+
+// chainSources returns a result for each distinct source_profile of the
+// chained profiles among results that still need fetching.
+func chainSources(results []*batchResult) []*batchResult {
+	var sources []*batchResult
+	seen := map[string]bool{}
+	for _, r := range results {
+		if r.err != nil || r.profile == nil || r.validUntil != "" || !isChainedProfile(r.profile) {
+			continue
+		}
+		name := r.profile.Key("source_profile").String()
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		source := &batchResult{profileName: name}
+		source.profile, source.err = retrieveProfile(name)
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// runFetchPool is the worker pool behind fetchBatch.
+func runFetchPool(results []*batchResult, concurrency int) {
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, r := range results {
+		if r.err != nil || r.profile == nil || r.validUntil != "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *batchResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.roleCred, r.err = getRoleCredentials(r.profileName, r.profile)
+		}(r)
+	}
+	wg.Wait()
+}
+
+// printBatchSummary writes one line per profile and returns the number of failures.
+func printBatchSummary(w io.Writer, results []*batchResult) int {
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROFILE\tSTATUS\tDETAIL")
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\tfailed\t%v\n", r.profileName, redact.Error(r.err))
+			continue
+		}
+		if r.validUntil != "" {
+			fmt.Fprintf(tw, "%s\tskipped\tvalid until %s\n", r.profileName, r.validUntil)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\tok\texpires %s\n", r.profileName, r.roleCred.Expiration)
+	}
+	_ = tw.Flush()
+	return failed
+}
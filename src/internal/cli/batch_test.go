@@ -0,0 +1,273 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-ini/ini"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+)
+
+const batchConfig = `[profile prod-app]
+sso_session = corp
+sso_account_id = 111111111111
+sso_role_name = Admin
+
+[profile prod-data]
+sso_session = corp
+sso_account_id = 222222222222
+sso_role_name = Admin
+
+[profile dev-app]
+sso_start_url = https://other.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 333333333333
+sso_role_name = Admin
+
+[profile no-access]
+sso_session = corp
+sso_account_id = 444444444444
+sso_role_name = Admin
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+`
+
+// loginGatedSource requires a login for each SSO token before it hands out
+// credentials, and denies access to selected profiles.
+type loginGatedSource struct {
+	mu       sync.Mutex
+	loggedIn map[string]bool
+	denied   map[string]bool
+}
+
+func (s *loginGatedSource) Retrieve(profileName string, profile *ini.Section) (*model.RoleCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.denied[profileName] {
+		return nil, errSSORoleNoAccess
+	}
+	if !s.loggedIn[profile.Key("sso_start_url").String()] {
+		return nil, errSSOLoginRequired
+	}
+	return &model.RoleCredential{AccessKeyId: "AKIA" + strings.ToUpper(profileName), Expiration: "2099-01-01T00:00:00Z"}, nil
+}
+
+func TestSelectImportProfiles(t *testing.T) {
+	writeAwsConfig(t, batchConfig)
+
+	cases := []struct {
+		name     string
+		names    []string
+		patterns []string
+		all      bool
+		want     []string
+		wantErr  string
+	}{
+		{name: "explicit names are de-duplicated and sorted", names: []string{"b", "a", "b"}, want: []string{"a", "b"}},
+		{name: "glob match", patterns: []string{"prod-*"}, want: []string{"prod-app", "prod-data"}},
+		{name: "names and globs combined", names: []string{"dev-app"}, patterns: []string{"prod-a*"}, want: []string{"dev-app", "prod-app"}},
+		{name: "all", all: true, want: []string{"dev-app", "no-access", "prod-app", "prod-data"}},
+		{name: "no match", patterns: []string{"staging-*"}, wantErr: `no SSO profiles match "staging-*"`},
+		{name: "bad pattern", patterns: []string{"["}, wantErr: "invalid --match pattern"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := selectImportProfiles(tc.names, tc.patterns, tc.all)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("selectImportProfiles() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestImportBatch verifies one login per start URL, a single credentials file
+// write containing every successful profile, and a summary with failures.
+func TestImportBatch(t *testing.T) {
+	writeAwsConfig(t, batchConfig)
+	dir := isolateAwsPaths(t)
+
+	source := &loginGatedSource{loggedIn: map[string]bool{}, denied: map[string]bool{"no-access": true}}
+	credentialSources["gated"] = source
+	origFlag := credentialSourceFlag
+	credentialSourceFlag = "gated"
+	t.Cleanup(func() {
+		credentialSourceFlag = origFlag
+		delete(credentialSources, "gated")
+	})
+
+	var logins []string
+	origLogin := runSSOLogin
+	t.Cleanup(func() { runSSOLogin = origLogin })
+	runSSOLogin = func(profile *ini.Section) error {
+		startURL := profile.Key("sso_start_url").String()
+		logins = append(logins, startURL)
+		source.mu.Lock()
+		source.loggedIn[startURL] = true
+		source.mu.Unlock()
+		return nil
+	}
+
+	var summary bytes.Buffer
+	origOut := summaryOutput
+	summaryOutput = &summary
+	t.Cleanup(func() { summaryOutput = origOut })
+
+	err := importBatch([]string{"dev-app", "missing", "no-access", "prod-app", "prod-data"}, importOptions{concurrency: 2})
+	if err == nil || err.Error() != "2 of 5 profiles failed to import" {
+		t.Fatalf("expected 2 failures, got %v", err)
+	}
+	if len(logins) != 2 {
+		t.Fatalf("expected one login per start URL, got %v", logins)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "credentials"))
+	if err != nil {
+		t.Fatalf("read credentials: %v", err)
+	}
+	for _, want := range []string{"AKIADEV-APP", "AKIAPROD-APP", "AKIAPROD-DATA"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("credentials file missing %q:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "[no-access]") {
+		t.Fatalf("failed profile should not be written:\n%s", data)
+	}
+
+	out := summary.String()
+	for _, want := range []string{"prod-app", "ok", "no-access", "failed", "missing"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("summary missing %q:\n%s", want, out)
+		}
+	}
+}
+
+// TestImportBatch_SkipsValidAndPrunes verifies that still-valid imported
+// sections are skipped and reported, and that --prune drops expired ones.
+func TestImportBatch_SkipsValidAndPrunes(t *testing.T) {
+	writeAwsConfig(t, batchConfig)
+	dir := isolateAwsPaths(t)
+	t.Setenv(refreshWindowEnv, "")
+	credsPath := filepath.Join(dir, "credentials")
+	fixture := `[prod-app]
+aws_access_key_id = AKIAKEPT
+aws_expiration = 2099-01-01T00:00:00Z
+sso_login_source_profile = prod-app
+
+[old-app]
+aws_access_key_id = AKIAOLD
+aws_expiration = 2000-01-01T00:00:00Z
+sso_login_source_profile = old-app
+`
+	if err := os.WriteFile(credsPath, []byte(fixture), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	useStaticSource(t, StaticSource{Credential: &model.RoleCredential{AccessKeyId: "AKIANEW", Expiration: "2099-01-01T00:00:00Z"}})
+
+	var summary bytes.Buffer
+	origOut := summaryOutput
+	summaryOutput = &summary
+	t.Cleanup(func() { summaryOutput = origOut })
+
+	if err := importBatch([]string{"prod-app", "prod-data"}, importOptions{prune: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(credsPath)
+	if err != nil {
+		t.Fatalf("read credentials: %v", err)
+	}
+	out := string(data)
+	if !strings.Contains(out, "AKIAKEPT") || !strings.Contains(out, "[prod-data]") || strings.Contains(out, "[old-app]") {
+		t.Fatalf("unexpected credentials file:\n%s", out)
+	}
+	if !strings.Contains(summary.String(), "skipped  valid until 2099-01-01T00:00:00Z") {
+		t.Fatalf("expected prod-app to be reported as skipped:\n%s", summary.String())
+	}
+}
+
+// TestImportBatch_SerializesMFAPrompts imports two chained profiles with an
+// mfa_serial in parallel and verifies that their MFA codes are read one at a
+// time.
+func TestImportBatch_SerializesMFAPrompts(t *testing.T) {
+	setupChained(t)
+	writeAwsConfig(t, chainedConfig+`
+[profile audit]
+role_arn = arn:aws:iam::333333333333:role/Audit
+source_profile = base
+mfa_serial = arn:aws:iam::111111111111:mfa/me
+`)
+
+	var active, maxActive, prompts int32
+	readMFATokenCode = func(profileName, _ string) (string, error) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		atomic.AddInt32(&prompts, 1)
+		for {
+			m := atomic.LoadInt32(&maxActive)
+			if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		// Give a concurrent prompt time to start.
+		time.Sleep(20 * time.Millisecond)
+		return "123456", nil
+	}
+
+	var summary bytes.Buffer
+	origOut := summaryOutput
+	summaryOutput = &summary
+	t.Cleanup(func() { summaryOutput = origOut })
+
+	if err := importBatch([]string{"audit", "deploy"}, importOptions{concurrency: 2}); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, summary.String())
+	}
+	if prompts != 2 {
+		t.Errorf("got %d MFA prompts, want 2", prompts)
+	}
+	if maxActive != 1 {
+		t.Errorf("MFA prompts overlapped: %d ran at once", maxActive)
+	}
+}
+
+// TestImportBatch_FetchesSharedSourceOnce verifies that chained profiles
+// sharing a source_profile fetch its credentials from SSO only once.
+func TestImportBatch_FetchesSharedSourceOnce(t *testing.T) {
+	_, portalCalls, stsFake := setupChained(t)
+	writeAwsConfig(t, chainedConfig+`
+[profile audit]
+role_arn = arn:aws:iam::333333333333:role/Audit
+source_profile = base
+`)
+
+	var summary bytes.Buffer
+	origOut := summaryOutput
+	summaryOutput = &summary
+	t.Cleanup(func() { summaryOutput = origOut })
+
+	if err := importBatch([]string{"audit", "deploy"}, importOptions{concurrency: 2}); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, summary.String())
+	}
+	if *portalCalls != 1 {
+		t.Errorf("got %d SSO portal calls, want 1 for the shared source profile", *portalCalls)
+	}
+	if stsFake.calls != 2 {
+		t.Errorf("got %d STS calls, want 2", stsFake.calls)
+	}
+}
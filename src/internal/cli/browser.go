@@ -1,29 +1,154 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/witnsby/aws-sso-login/src/internal/redact"
 )
 
-// By default, these point to the real "exec.Command" and "fmt.Printf".
+// By default, these point to the real "exec.Command", "fmt.Printf" and the system clipboard.
 var (
-	execCommand = exec.Command
-	printFunc   = fmt.Printf
+	execCommand     = exec.Command
+	printFunc       = fmt.Printf
+	copyToClipboard = clipboard.WriteAll
 )
 
+// browserURLPlaceholder marks where the URL goes in a --browser command template.
+const browserURLPlaceholder = "{url}"
+
 // openBrowser tries to open a browser using `xdg-open`, `open`, or `start`.
+// If none starts, the URL is printed instead, unless it carries a secret such
+// as a console sign-in token; then the user is pointed at --print or --copy,
+// which hand the URL over deliberately.
 func openBrowser(targetURL string) {
+	if startBrowser(targetURL) {
+		return
+	}
+	if redact.ContainsSecret(targetURL) {
+		printFunc("Could not open a browser for %s (sign-in token hidden).\n"+
+			"Run console with --print or --copy to get the full URL.\n", redact.String(targetURL))
+		return
+	}
+	printFunc("Please open your browser and navigate to: %s\n", targetURL)
+}
+
+// startBrowser launches the platform URL handler and reports whether one started.
+func startBrowser(targetURL string) bool {
 	// For Linux
 	if execCommand("xdg-open", targetURL).Start() == nil {
-		return
+		return true
 	}
 	// For macOS
 	if execCommand("open", targetURL).Start() == nil {
-		return
+		return true
 	}
 	// For Windows
-	if execCommand("rundll32", "url.dll,FileProtocolHandler", targetURL).Start() == nil {
-		return
+	return execCommand("rundll32", "url.dll,FileProtocolHandler", targetURL).Start() == nil
+}
+
+// browserOpener returns the function used to open console URLs. An empty
+// template uses the platform URL handler (see openBrowser); otherwise the
+// template is run as a command with {url} replaced by the URL, or the URL
+// appended as the last argument when the template has no placeholder.
+func browserOpener(template string) (func(string) error, error) {
+	if strings.TrimSpace(template) == "" {
+		return func(targetURL string) error {
+			openBrowser(targetURL)
+			return nil
+		}, nil
 	}
-	printFunc("Please open your browser and navigate to: %s\n", targetURL)
+	args, err := splitCommandLine(template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --browser command %q: %w", template, err)
+	}
+	return func(targetURL string) error {
+		return startCommand(browserCommandArgs(args, targetURL))
+	}, nil
+}
+
+// startCommand starts argv without waiting for it to exit.
+func startCommand(argv []string) error {
+	if err := execCommand(argv[0], argv[1:]...).Start(); err != nil {
+		return fmt.Errorf("failed to start browser %q: %w", argv[0], err)
+	}
+	return nil
+}
+
+// browserCommandArgs substitutes targetURL into a split command template.
+func browserCommandArgs(args []string, targetURL string) []string {
+	argv := make([]string, 0, len(args)+1)
+	substituted := false
+	for _, arg := range args {
+		if strings.Contains(arg, browserURLPlaceholder) {
+			arg = strings.ReplaceAll(arg, browserURLPlaceholder, targetURL)
+			substituted = true
+		}
+		argv = append(argv, arg)
+	}
+	if !substituted {
+		argv = append(argv, targetURL)
+	}
+	return argv
+}
+
+// splitCommandLine splits a command template into arguments. Whitespace
+// separates arguments; single quotes preserve text literally, and double
+// quotes preserve text except for backslash-escaped quotes and backslashes.
+// No other shell expansion is performed.
+func splitCommandLine(s string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return args, nil
 }
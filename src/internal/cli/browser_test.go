@@ -6,6 +6,8 @@ import (
 	"os/exec"
 	"strings"
 	"testing"
+
+	"github.com/atotto/clipboard"
 )
 
 func TestOpenBrowser(t *testing.T) {
@@ -66,3 +68,96 @@ func TestOpenBrowser(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "firefox", want: []string{"firefox"}},
+		{in: "firefox --new-tab {url}", want: []string{"firefox", "--new-tab", "{url}"}},
+		{in: `"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome" --profile-directory='Profile 2'`,
+			want: []string{"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome", "--profile-directory=Profile 2"}},
+		{in: `open -a Firefox\ Developer\ Edition`, want: []string{"open", "-a", "Firefox Developer Edition"}},
+		{in: `firefox "unterminated`, wantErr: true},
+		{in: "   ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := splitCommandLine(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("splitCommandLine(%q): expected error, got %q", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitCommandLine(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestBrowserOpener_CommandTemplate(t *testing.T) {
+	var gotName string
+	var gotArgs []string
+	execCommand = func(name string, arg ...string) *exec.Cmd {
+		gotName, gotArgs = name, arg
+		return exec.Command("true")
+	}
+	defer func() { execCommand = exec.Command }()
+
+	open, err := browserOpener("firefox --new-tab 'ext+container:name=dev&url={url}'")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := open("https://example.com/?a=b"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotName != "firefox" || strings.Join(gotArgs, " ") != "--new-tab ext+container:name=dev&url=https://example.com/?a=b" {
+		t.Fatalf("unexpected command %q %q", gotName, gotArgs)
+	}
+
+	open, _ = browserOpener("chromium --incognito")
+	_ = open("https://example.com")
+	if gotName != "chromium" || strings.Join(gotArgs, " ") != "--incognito https://example.com" {
+		t.Fatalf("expected URL to be appended, got %q %q", gotName, gotArgs)
+	}
+}
+
+func TestDeliverSigninURL(t *testing.T) {
+	var stdout bytes.Buffer
+	printFunc = func(format string, a ...any) (n int, err error) {
+		return stdout.WriteString(fmt.Sprintf(format, a...))
+	}
+	defer func() { printFunc = fmt.Printf }()
+
+	var copied string
+	copyToClipboard = func(text string) error {
+		copied = text
+		return nil
+	}
+	defer func() { copyToClipboard = clipboard.WriteAll }()
+
+	execCommand = func(name string, arg ...string) *exec.Cmd {
+		t.Fatalf("no browser should be started, got %s", name)
+		return nil
+	}
+	defer func() { execCommand = exec.Command }()
+
+	if err := deliverSigninURL("https://signin.example/federation", true, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdout.String() != "https://signin.example/federation\n" {
+		t.Errorf("unexpected stdout %q", stdout.String())
+	}
+	if copied != "https://signin.example/federation" {
+		t.Errorf("unexpected clipboard contents %q", copied)
+	}
+
+	copyToClipboard = func(string) error { return fmt.Errorf("no clipboard utility") }
+	if err := deliverSigninURL("https://signin.example/federation", false, true); err == nil ||
+		!strings.Contains(err.Error(), "clipboard") {
+		t.Errorf("expected clipboard error, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/go-ini/ini"
+	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+	"github.com/witnsby/aws-sso-login/src/internal/profiles"
+)
+
+// cliCacheKey returns the name (without .json) of the ~/.aws/cli/cache entry
+// the AWS CLI v2 uses for the profile's role credentials. Like botocore, it is
+// the sha1 of the fetcher's arguments serialised by Python's json.dumps with
+// sorted keys:
+//
+//   - SSO profiles hash {"accountId", "roleName", "sessionName"} with compact
+//     separators, or "startUrl" instead of "sessionName" for legacy profiles.
+//   - Chained profiles hash their AssumeRole arguments (RoleArn, ExternalId,
+//     SerialNumber, DurationSeconds, and RoleSessionName when it is configured
+//     rather than generated) with the default ", " and ": " separators.
+func cliCacheKey(profile *ini.Section) string {
+	if isChainedProfile(profile) {
+		return assumeRoleCacheKey(profile)
+	}
+	args := map[string]any{
+		"roleName":  profile.Key("sso_role_name").String(),
+		"accountId": profile.Key("sso_account_id").String(),
+	}
+	if sessionName := profile.Key("sso_session").String(); sessionName != "" {
+		args["sessionName"] = sessionName
+	} else {
+		args["startUrl"] = profile.Key("sso_start_url").String()
+	}
+	return sha1Hex(pythonJSON(args, ",", ":"))
+}
+
+// assumeRoleCacheKey returns the cache key of a chained profile.
+func assumeRoleCacheKey(profile *ini.Section) string {
+	args := map[string]any{"RoleArn": profile.Key("role_arn").String()}
+	for key, arg := range map[string]string{
+		"role_session_name": "RoleSessionName",
+		"external_id":       "ExternalId",
+		"mfa_serial":        "SerialNumber",
+	} {
+		if v := profile.Key(key).String(); v != "" {
+			args[arg] = v
+		}
+	}
+	if v, err := profile.Key("duration_seconds").Int(); err == nil {
+		args["DurationSeconds"] = v
+	}
+	return sha1Hex(pythonJSON(args, ", ", ": "))
+}
+
+func sha1Hex(s string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(s)))
+}
+
+// pythonJSON serialises a flat object of strings and ints exactly like
+// Python's json.dumps(args, sort_keys=True, separators=(itemSep, keySep)):
+// keys sorted by code point, and every character outside printable ASCII
+// escaped as \uXXXX (surrogate pairs above U+FFFF).
+func pythonJSON(args map[string]any, itemSep, keySep string) string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]string, len(keys))
+	for i, k := range keys {
+		var value string
+		switch v := args[k].(type) {
+		case int:
+			value = strconv.Itoa(v)
+		default:
+			value = pythonQuote(fmt.Sprint(v))
+		}
+		fields[i] = pythonQuote(k) + keySep + value
+	}
+	return "{" + strings.Join(fields, itemSep) + "}"
+}
+
+// pythonQuote quotes s the way Python's json module does with ensure_ascii.
+func pythonQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			switch {
+			case r >= 0x20 && r < 0x7f:
+				b.WriteRune(r)
+			case r > 0xffff:
+				r1, r2 := utf16.EncodeRune(r)
+				fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+			default:
+				fmt.Fprintf(&b, `\u%04x`, r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// cacheEntry is the subset of an ~/.aws/cli/cache file the scan inspects.
+// Assume-role entries carry the AssumeRole response, including the assumed
+// role's ARN; SSO entries only record the account, not the role.
+type cacheEntry struct {
+	ProviderType    string               `json:"ProviderType"`
+	Credentials     model.RoleCredential `json:"Credentials"`
+	AssumedRoleUser struct {
+		Arn string `json:"Arn"`
+	} `json:"AssumedRoleUser"`
+}
+
+// scanCachedRoleCredentials looks through every entry in cacheDir for
+// unexpired credentials that provably belong to the profile's role, for cache
+// keys this tool cannot derive (e.g. written by another CLI version). Only
+// assume-role entries qualify, matched on account and role name through
+// AssumedRoleUser.Arn: the AWS CLI's SSO entries name the account but not the
+// role, so matching them could hand out another role's credentials. Of
+// several matches the one expiring last wins; nil means no match.
+func scanCachedRoleCredentials(cacheDir string, profile *ini.Section) *model.RoleCredential {
+	if !isChainedProfile(profile) {
+		return nil
+	}
+	account, role, err := profiles.ParseRoleARN(profile.Key("role_arn").String())
+	if err != nil {
+		return nil
+	}
+	prefix := fmt.Sprintf(":%s:assumed-role/%s/", account, role)
+
+	paths, _ := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	var best *model.RoleCredential
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var entry cacheEntry
+		if json.Unmarshal(data, &entry) != nil || !strings.Contains(entry.AssumedRoleUser.Arn, prefix) {
+			continue
+		}
+		expiration, err := parseExpirationTime(entry.Credentials.Expiration)
+		if err != nil || isExpired(entry.Credentials.Expiration) {
+			continue
+		}
+		if best == nil {
+			best = &entry.Credentials
+			logrus.Debugf("Matched %s to %s by its assumed role", path, role)
+			continue
+		}
+		if bestExpiration, _ := parseExpirationTime(best.Expiration); expiration.After(bestExpiration) {
+			best = &entry.Credentials
+			logrus.Debugf("Matched %s to %s by its assumed role", path, role)
+		}
+	}
+	return best
+}
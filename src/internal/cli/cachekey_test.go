@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-ini/ini"
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+)
+
+// The testdata/cli-cache fixtures hold a config with one profile per shape
+// and the cache entries the AWS CLI v2 writes for them. The entries come from
+// testdata/cli-cache/capture, which runs the real `aws` against a local fake
+// of SSO and STS, so the tests below check this tool's file names against
+// the CLI's own. The entries checked in so far were derived with botocore's
+// recipe rather than captured; rerun the capture with aws v2 installed to
+// replace them, and whenever a profile shape is added. The unknown-*.json
+// entries are written by hand: they stand in for keys this tool cannot
+// derive and are only found by scanning.
+
+func useCLICacheFixtures(t *testing.T) {
+	t.Helper()
+	isolateAwsPaths(t)
+	dir, err := filepath.Abs(filepath.Join("testdata", "cli-cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv(helper.CliCacheDirEnv, filepath.Join(dir, "cache"))
+}
+
+func TestCliCacheKey_Fixtures(t *testing.T) {
+	useCLICacheFixtures(t)
+	// Each profile must resolve to the entry the CLI wrote for it, identified
+	// by the access key ID the capture's fake hands out.
+	keyIDs := map[string]string{
+		"legacy":          "ASIALEGACY",
+		"session":         "ASIASESSION",
+		"unicode-session": "ASIAUNICODE",
+		"chained-minimal": "ASIADEPLOY",
+		"chained-full":    "ASIAAUDIT",
+	}
+	used := map[string]bool{}
+	for profileName, keyID := range keyIDs {
+		t.Run(profileName, func(t *testing.T) {
+			profile, err := retrieveProfile(profileName)
+			if err != nil {
+				t.Fatalf("retrieveProfile: %v", err)
+			}
+			path, err := buildCacheFilePath(profile)
+			if err != nil {
+				t.Fatalf("buildCacheFilePath: %v", err)
+			}
+			used[filepath.Base(path)] = true
+			if _, err := os.Stat(path); err != nil {
+				t.Fatalf("no CLI cache entry under the derived name: %v", err)
+			}
+			roleCred, err := getCachedRoleCredentials(profile)
+			if err != nil {
+				t.Fatalf("getCachedRoleCredentials: %v", err)
+			}
+			if roleCred.AccessKeyId != keyID {
+				t.Errorf("%s holds %s, want %s", filepath.Base(path), roleCred.AccessKeyId, keyID)
+			}
+		})
+	}
+
+	// Every captured entry belongs to one of the profiles above.
+	captured, _ := filepath.Glob(filepath.Join(os.Getenv(helper.CliCacheDirEnv), "*.json"))
+	for _, path := range captured {
+		if name := filepath.Base(path); !strings.HasPrefix(name, "unknown-") && !used[name] {
+			t.Errorf("captured entry %s matches no profile", name)
+		}
+	}
+}
+
+func TestPythonJSON(t *testing.T) {
+	// Expected strings produced by Python's json.dumps(args, sort_keys=True, ...).
+	tests := []struct {
+		args            map[string]any
+		compact, spaced string
+	}{
+		{
+			map[string]any{"b": "é", "a": `x"y\z`},
+			`{"a":"x\"y\\z","b":"\u00e9"}`,
+			`{"a": "x\"y\\z", "b": "\u00e9"}`,
+		},
+		{
+			map[string]any{"k": "😀 <>&\t", "n": 3600},
+			`{"k":"\ud83d\ude00 <>&\t","n":3600}`,
+			`{"k": "\ud83d\ude00 <>&\t", "n": 3600}`,
+		},
+	}
+	for _, tt := range tests {
+		if got := pythonJSON(tt.args, ",", ":"); got != tt.compact {
+			t.Errorf("compact = %s, want %s", got, tt.compact)
+		}
+		if got := pythonJSON(tt.args, ", ", ": "); got != tt.spaced {
+			t.Errorf("spaced = %s, want %s", got, tt.spaced)
+		}
+	}
+}
+
+func TestScanCachedRoleCredentials(t *testing.T) {
+	useCLICacheFixtures(t)
+
+	// The latest unexpired entry for the role wins; the expired one, another
+	// role with a shared prefix and SSO entries are ignored.
+	profile, err := retrieveProfile("scanned")
+	if err != nil {
+		t.Fatalf("retrieveProfile: %v", err)
+	}
+	roleCred, err := getCachedRoleCredentials(profile)
+	if err != nil {
+		t.Fatalf("getCachedRoleCredentials: %v", err)
+	}
+	if roleCred.AccessKeyId != "ASIASCANNED" {
+		t.Errorf("AccessKeyId = %s, want ASIASCANNED", roleCred.AccessKeyId)
+	}
+
+	// SSO profiles are never matched by scanning.
+	cfg, _ := ini.Load([]byte("[profile other]\nsso_session = corp\nsso_account_id = 222222222222\nsso_role_name = Admin\n"))
+	if got := scanCachedRoleCredentials(os.Getenv(helper.CliCacheDirEnv), cfg.Section("profile other")); got != nil {
+		t.Errorf("SSO profile matched %s", got.AccessKeyId)
+	}
+
+	// Without a matching entry, the lookup reports the missing file.
+	cfg, _ = ini.Load([]byte("[profile none]\nrole_arn = arn:aws:iam::222222222222:role/Missing\n"))
+	if _, err := getCachedRoleCredentials(cfg.Section("profile none")); !os.IsNotExist(err) {
+		t.Errorf("err = %v, want not exist", err)
+	}
+}
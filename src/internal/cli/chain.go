@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ini/ini"
+	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+	"github.com/witnsby/aws-sso-login/src/internal/profiles"
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
+	"github.com/witnsby/aws-sso-login/src/internal/sts"
+)
+
+// Limits STS accepts for an AssumeRole duration_seconds.
+const (
+	minAssumeRoleDuration = 900
+	maxAssumeRoleDuration = 43200
+)
+
+// errAssumeRoleFailed indicates that STS refused or could not be asked to
+// assume a chained profile's role_arn. Logging in to SSO again cannot fix it.
+var errAssumeRoleFailed = errors.New("failed to assume role")
+
+// By default, these point to the real STS client and the terminal prompt.
+var (
+	newSTSClient     = sts.NewClient
+	readMFATokenCode = promptMFATokenCode
+)
+
+// mfaMu serializes MFA prompts. Batch imports assume roles in parallel, and
+// their prompts and reads on the one terminal must not interleave.
+var mfaMu sync.Mutex
+
+// mfaInput reads MFA codes from stdin. It is shared so that input buffered
+// while reading one code is not lost to the next prompt.
+var mfaInput *bufio.Reader
+
+// isChainedProfile reports whether the profile assumes role_arn on top of a
+// source_profile instead of using an SSO role directly.
+func isChainedProfile(profile *ini.Section) bool {
+	return profile.Key("role_arn").String() != ""
+}
+
+// profileAccountAndRole returns the account ID and role name the profile's
+// credentials belong to: from role_arn for chained profiles, otherwise from
+// sso_account_id and sso_role_name.
+func profileAccountAndRole(profile *ini.Section) (string, string) {
+	if isChainedProfile(profile) {
+		account, role, err := profiles.ParseRoleARN(profile.Key("role_arn").String())
+		if err != nil {
+			return "", ""
+		}
+		return account, role
+	}
+	return profile.Key("sso_account_id").String(), profile.Key("sso_role_name").String()
+}
+
+// assumeRoleInput builds the AssumeRole parameters from the profile's
+// role_arn, role_session_name, external_id, duration_seconds and mfa_serial.
+// The MFA code is not filled in.
+func assumeRoleInput(profile *ini.Section) (sts.AssumeRoleInput, error) {
+	in := sts.AssumeRoleInput{
+		RoleARN:         profile.Key("role_arn").String(),
+		RoleSessionName: profile.Key("role_session_name").String(),
+		ExternalID:      profile.Key("external_id").String(),
+		SerialNumber:    profile.Key("mfa_serial").String(),
+	}
+	if raw := profile.Key("duration_seconds").String(); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < minAssumeRoleDuration || seconds > maxAssumeRoleDuration {
+			return in, fmt.Errorf("invalid duration_seconds %q: must be between %d and %d",
+				raw, minAssumeRoleDuration, maxAssumeRoleDuration)
+		}
+		in.DurationSeconds = seconds
+	}
+	return in, nil
+}
+
+// assumeRoleCredentials returns credentials for a chained profile: it gets
+// credentials for the profile's source_profile (itself chained or SSO, through
+// the usual credential source and cache) and uses them to call STS AssumeRole.
+//
+// Errors from the source profile are returned unchanged, so a missing SSO
+// token still surfaces as errSSOLoginRequired; STS and MFA failures wrap
+// errAssumeRoleFailed.
+func assumeRoleCredentials(profileName string, profile *ini.Section) (*model.RoleCredential, error) {
+	in, err := assumeRoleInput(profile)
+	if err != nil {
+		return nil, fmt.Errorf("%w for profile %q: %v", errAssumeRoleFailed, profileName, err)
+	}
+
+	sourceName := profile.Key("source_profile").String()
+	source, err := retrieveProfile(sourceName)
+	if err != nil {
+		return nil, err
+	}
+	sourceCred, err := getRoleCredentials(sourceName, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.RoleSessionName == "" {
+		in.RoleSessionName = fmt.Sprintf("aws-sso-login-%d", time.Now().Unix())
+	}
+	if in.SerialNumber != "" {
+		mfaMu.Lock()
+		in.TokenCode, err = readMFATokenCode(profileName, in.SerialNumber)
+		mfaMu.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("%w for profile %q: %v", errAssumeRoleFailed, profileName, err)
+		}
+	}
+
+	client := newSTSClient(consoleRegion(profile, ""))
+	out, err := client.AssumeRole(context.Background(), sts.Credentials{
+		AccessKeyID:     sourceCred.AccessKeyId,
+		SecretAccessKey: sourceCred.SecretAccessKey,
+		SessionToken:    sourceCred.SessionToken,
+	}, in)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s for profile %q: %v", errAssumeRoleFailed, in.RoleARN, profileName, err)
+	}
+	logrus.Infof("Assumed role %s from profile %s", in.RoleARN, sourceName)
+
+	return &model.RoleCredential{
+		AccessKeyId:     out.Credentials.AccessKeyID,
+		SecretAccessKey: out.Credentials.SecretAccessKey,
+		SessionToken:    out.Credentials.SessionToken,
+		Expiration:      out.Credentials.Expiration.UTC().Format(sso.TimeFormat),
+	}, nil
+}
+
+// promptMFATokenCode reads an MFA code from the terminal, prompting on stderr
+// so stdout stays machine-readable. Callers hold mfaMu.
+func promptMFATokenCode(profileName, serial string) (string, error) {
+	if !isTerminal(os.Stdin.Fd()) {
+		return "", fmt.Errorf("an MFA code for %s is required but stdin is not a terminal", serial)
+	}
+	fmt.Fprintf(os.Stderr, "Enter MFA code for %s (profile %s): ", serial, profileName)
+	if mfaInput == nil {
+		mfaInput = bufio.NewReader(os.Stdin)
+	}
+	line, err := mfaInput.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("reading MFA code: %w", err)
+	}
+	code := strings.TrimSpace(line)
+	if code == "" {
+		return "", errors.New("no MFA code entered")
+	}
+	return code, nil
+}
@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ini/ini"
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
+	"github.com/witnsby/aws-sso-login/src/internal/sts"
+)
+
+const chainedConfig = `[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+
+[profile base]
+sso_session = corp
+sso_account_id = 111111111111
+sso_role_name = Admin
+
+[profile deploy]
+role_arn = arn:aws:iam::333333333333:role/Deploy
+source_profile = base
+external_id = ext-id
+duration_seconds = 3600
+mfa_serial = arn:aws:iam::111111111111:mfa/me
+region = eu-west-1
+`
+
+// fakeSTS serves AssumeRole, records the last request form and counts calls.
+// When denied is set it answers with AccessDenied instead.
+type fakeSTS struct {
+	mu     sync.Mutex
+	calls  int
+	form   url.Values
+	auth   string
+	denied bool
+}
+
+func (f *fakeSTS) install(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.calls++
+		body, _ := io.ReadAll(r.Body)
+		f.form, _ = url.ParseQuery(string(body))
+		f.auth = r.Header.Get("Authorization")
+		if f.denied {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `<ErrorResponse><Error><Code>AccessDenied</Code><Message>denied</Message></Error></ErrorResponse>`)
+			return
+		}
+		_, _ = io.WriteString(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials>`+
+			`<AccessKeyId>ASIACHAINED</AccessKeyId><SecretAccessKey>chained-secret</SecretAccessKey>`+
+			`<SessionToken>chained-token</SessionToken><Expiration>`+time.Now().Add(time.Hour).UTC().Format(time.RFC3339)+`</Expiration>`+
+			`</Credentials></AssumeRoleResult></AssumeRoleResponse>`)
+	}))
+	t.Cleanup(srv.Close)
+
+	orig := newSTSClient
+	t.Cleanup(func() { newSTSClient = orig })
+	newSTSClient = func(region string) *sts.Client {
+		return &sts.Client{Endpoint: srv.URL, Region: region, HTTPClient: srv.Client()}
+	}
+}
+
+// setupChained writes the chained config, caches an SSO token for corp, and
+// installs the fake portal, fake STS and a fixed MFA code.
+func setupChained(t *testing.T) (dir string, portalCalls *int, stsFake *fakeSTS) {
+	t.Helper()
+	writeAwsConfig(t, chainedConfig)
+	dir = isolateAwsPaths(t)
+	t.Setenv(credentialSourceEnv, "")
+	token := &sso.CachedToken{AccessToken: "access-token", ExpiresAt: time.Now().Add(time.Hour).UTC().Format(sso.TimeFormat)}
+	if err := sso.SaveToken(filepath.Join(dir, "sso", "cache"), sso.TokenCacheKey("corp", ""), token); err != nil {
+		t.Fatalf("save token: %v", err)
+	}
+	portalCalls = fakePortal(t, time.Hour)
+	stsFake = &fakeSTS{}
+	stsFake.install(t)
+
+	orig := readMFATokenCode
+	t.Cleanup(func() { readMFATokenCode = orig })
+	readMFATokenCode = func(_, serial string) (string, error) {
+		if serial != "arn:aws:iam::111111111111:mfa/me" {
+			t.Errorf("unexpected MFA serial %q", serial)
+		}
+		return "123456", nil
+	}
+	return dir, portalCalls, stsFake
+}
+
+func TestChainedProfile_AssumesRoleAndCaches(t *testing.T) {
+	dir, portalCalls, stsFake := setupChained(t)
+
+	profile, err := retrieveProfile("deploy")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cred, err := getRoleCredentials("deploy", profile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cred.AccessKeyId != "ASIACHAINED" || cred.SessionToken != "chained-token" {
+		t.Fatalf("got %+v, want the assumed-role credentials", cred)
+	}
+	if *portalCalls != 1 || stsFake.calls != 1 {
+		t.Fatalf("got %d portal and %d STS calls, want 1 each", *portalCalls, stsFake.calls)
+	}
+	if !strings.Contains(stsFake.auth, "Credential=ASIAFRESH/") || !strings.Contains(stsFake.auth, "/eu-west-1/sts/") {
+		t.Errorf("expected STS call signed with the base credentials in eu-west-1, got %q", stsFake.auth)
+	}
+	for key, want := range map[string]string{
+		"RoleArn":         "arn:aws:iam::333333333333:role/Deploy",
+		"ExternalId":      "ext-id",
+		"DurationSeconds": "3600",
+		"SerialNumber":    "arn:aws:iam::111111111111:mfa/me",
+		"TokenCode":       "123456",
+	} {
+		if got := stsFake.form.Get(key); got != want {
+			t.Errorf("AssumeRole %s = %q, want %q", key, got, want)
+		}
+	}
+	if !strings.HasPrefix(stsFake.form.Get("RoleSessionName"), "aws-sso-login-") {
+		t.Errorf("unexpected RoleSessionName %q", stsFake.form.Get("RoleSessionName"))
+	}
+
+	// Cached under the AWS CLI assume-role key, without the SSO provider type.
+	data, err := os.ReadFile(filepath.Join(dir, "cli", "cache", "6facffe28e1472eb2f0db83751256a1adf71a123.json"))
+	if err != nil {
+		t.Fatalf("expected assume-role cache file: %v", err)
+	}
+	var cached map[string]json.RawMessage
+	if err := json.Unmarshal(data, &cached); err != nil {
+		t.Fatalf("decode cache: %v", err)
+	}
+	if _, ok := cached["ProviderType"]; ok {
+		t.Errorf("assume-role cache entry should not carry ProviderType: %s", data)
+	}
+
+	// A second lookup is served from the cache.
+	if _, err := getRoleCredentials("deploy", profile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stsFake.calls != 1 {
+		t.Fatalf("expected cached credentials, got %d STS calls", stsFake.calls)
+	}
+}
+
+func TestChainedProfile_AssumeRoleDeniedSkipsLogin(t *testing.T) {
+	_, _, stsFake := setupChained(t)
+	stsFake.denied = true
+	logins := 0
+	origLogin := runSSOLogin
+	t.Cleanup(func() { runSSOLogin = origLogin })
+	runSSOLogin = func(*ini.Section) error {
+		logins++
+		return nil
+	}
+
+	manager := awsCredentialsManager{profileName: "deploy"}
+	err := manager.retrieveAndSetProfile()
+	if !errors.Is(err, errAssumeRoleFailed) || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Fatalf("expected errAssumeRoleFailed with AccessDenied, got %v", err)
+	}
+	if logins != 0 {
+		t.Fatalf("expected no SSO login, got %d", logins)
+	}
+}
+
+func TestChainedProfile_Validation(t *testing.T) {
+	writeAwsConfig(t, `[profile base]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 111111111111
+
+[profile deploy]
+role_arn = arn:aws:iam::333333333333:role/Deploy
+source_profile = base
+
+[profile bad-arn]
+role_arn = Deploy
+source_profile = base
+
+`)
+	if _, err := retrieveProfile("deploy"); err == nil || !strings.Contains(err.Error(), `missing required attribute "sso_role_name" in [profile base], the source of profile deploy`) {
+		t.Errorf("unexpected error for incomplete base: %v", err)
+	}
+	if _, err := retrieveProfile("bad-arn"); err == nil || !strings.Contains(err.Error(), `invalid role ARN "Deploy"`) {
+		t.Errorf("unexpected error for bad role_arn: %v", err)
+	}
+
+	cfg, _ := ini.Load([]byte("[p]\nduration_seconds = 60\n"))
+	if _, err := assumeRoleInput(cfg.Section("p")); err == nil || !strings.Contains(err.Error(), "between 900 and 43200") {
+		t.Errorf("unexpected error for duration_seconds: %v", err)
+	}
+}
+
+func TestAssumeRoleCacheKey(t *testing.T) {
+	// Expected values computed with Python's json.dumps(args, sort_keys=True), as the AWS CLI does.
+	cfg, _ := ini.Load([]byte(chainedConfig + "\n[profile minimal]\nrole_arn = arn:aws:iam::333333333333:role/Deploy\n" +
+		"\n[profile named]\nrole_arn = arn:aws:iam::333333333333:role/Deploy\nrole_session_name = deploy-session\n"))
+	if got := assumeRoleCacheKey(cfg.Section("profile deploy")); got != "6facffe28e1472eb2f0db83751256a1adf71a123" {
+		t.Errorf("deploy key = %s", got)
+	}
+	if got := assumeRoleCacheKey(cfg.Section("profile minimal")); got != "ecf35a03eb5a1e35eea06da7bf9daf0e3ab2842b" {
+		t.Errorf("minimal key = %s", got)
+	}
+	// A configured role_session_name is part of the key; leaving it out made
+	// chained profiles miss the entries the AWS CLI writes.
+	if got := assumeRoleCacheKey(cfg.Section("profile named")); got != "aa2b30670d5eea33633bc6936d18689a839da4b3" {
+		t.Errorf("named key = %s", got)
+	}
+}
+
+func TestProfileAccountAndRole(t *testing.T) {
+	cfg, _ := ini.Load([]byte(chainedConfig))
+	if account, role := profileAccountAndRole(cfg.Section("profile deploy")); account != "333333333333" || role != "Deploy" {
+		t.Errorf("chained profile: got %s/%s", account, role)
+	}
+	if account, role := profileAccountAndRole(cfg.Section("profile base")); account != "111111111111" || role != "Admin" {
+		t.Errorf("SSO profile: got %s/%s", account, role)
+	}
+}
@@ -8,78 +8,226 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/witnsby/aws-sso-login/src/internal/helper"
+	"github.com/witnsby/aws-sso-login/src/internal/logging"
 )
 
-// init initializes flags and options for various commands: consoleCmd, exportCmd, importCmd, and processCmd.
+// init initializes flags and options for various commands: configureCmd, consoleCmd, execCmd, exportCmd, importCmd, processCmd, serveCmd, and statusCmd.
 func init() {
-	consoleCmd.Flags().String("profile", "", "Name of the AWS profile")
+	configureCmd.Flags().String("sso-session", "", "Name of the [sso-session] whose accounts and roles are configured")
+	configureCmd.Flags().String("name-template", defaultProfileNameTemplate, "Go template for profile names; fields: AccountName, AccountID, RoleName, SessionName")
+	configureCmd.Flags().String("region", "", "Default region written to every generated profile")
+	configureCmd.Flags().Bool("dry-run", false, "Print a diff of the changes instead of writing the config file")
+	_ = configureCmd.MarkFlagRequired("sso-session")
+
+	consoleCmd.Flags().String("profile", "", "Name of the AWS profile (default $AWS_PROFILE, else chosen interactively)")
 	consoleCmd.Flags().Bool("force-logout", true, "Force logout of any existing session in the browser first")
 	consoleCmd.Flags().Int("logout-wait", 1, "Number of seconds to wait after forcing logout before logging in")
+	consoleCmd.Flags().String("destination", "", "Console URL or path to open after sign-in, e.g. /ec2/home")
+	consoleCmd.Flags().String("service", "", "Console service to open after sign-in, e.g. s3 or cloudwatch")
+	consoleCmd.Flags().String("region", "", "Console region (default: profile region, then sso_region)")
+	consoleCmd.Flags().Bool("print", false, "Print the sign-in URL to stdout instead of opening a browser")
+	consoleCmd.Flags().Bool("copy", false, "Copy the sign-in URL to the clipboard instead of opening a browser")
+	consoleCmd.Flags().String("browser", "", "Command used to open the URL, e.g. 'firefox --new-window {url}' (URL appended if {url} is absent)")
+	consoleCmd.Flags().String("isolation", "", "Browser isolation per profile: none, firefox-container or chromium (default: profile sso_login_browser_isolation, else none)")
+	consoleCmd.Flags().String("session-duration", "", "Console session length, e.g. 2h or 90m, between 15m and 12h (default: profile sso_login_session_duration, else 12h)")
+	consoleCmd.MarkFlagsMutuallyExclusive("destination", "service")
+	consoleCmd.MarkFlagsMutuallyExclusive("print", "browser")
+	consoleCmd.MarkFlagsMutuallyExclusive("copy", "browser")
+
+	exportCmd.Flags().String("profile", "", "Name of the AWS profile (default $AWS_PROFILE, else chosen interactively)")
+	exportCmd.Flags().String("format", "", "Output format: sh, fish, powershell, cmd, dotenv or json (default: detected from $SHELL)")
+
+	execCmd.Flags().String("profile", "", "Name of the AWS profile (default $AWS_PROFILE, else chosen interactively)")
+	// Everything after the command name belongs to the child, even without "--".
+	execCmd.Flags().SetInterspersed(false)
+
+	serveCmd.Flags().String("profile", "", "Name of the AWS profile (default $AWS_PROFILE, else chosen interactively)")
+	serveCmd.Flags().String("listen", "127.0.0.1:9911", "Loopback address of the container credentials endpoint")
+	serveCmd.Flags().Bool("imds", false, "Also serve an IMDSv2-compatible endpoint")
+	serveCmd.Flags().String("imds-listen", "127.0.0.1:9912", "Loopback address of the IMDSv2-compatible endpoint")
 
-	exportCmd.Flags().String("profile", "", "Name of the AWS profile")
+	statusCmd.Flags().String("profile", "", "Name of the AWS profile (default: all SSO profiles)")
+	statusCmd.Flags().StringP("output", "o", "table", "Output format: table or json")
 
-	importCmd.Flags().String("profile", "", "AWS profile name (omit to choose interactively)")
+	importCmd.Flags().StringArray("profile", nil, "AWS profile name; repeat to import several (omit to choose interactively)")
+	importCmd.Flags().StringArray("match", nil, "Import every SSO profile whose name matches this glob, e.g. 'prod-*'; repeatable")
+	importCmd.Flags().Bool("all", false, "Import every SSO profile in the AWS config file")
+	importCmd.Flags().Bool("multi", false, "Choose several profiles interactively when --profile is omitted")
+	importCmd.Flags().Int("concurrency", defaultImportConcurrency, "Maximum number of profiles fetched in parallel when importing several")
+	importCmd.Flags().Bool("backup", false, "Copy the credentials file to a timestamped .bak file before rewriting it")
+	importCmd.Flags().Bool("force", false, "Refresh profiles whose imported credentials are still valid")
+	importCmd.Flags().Bool("prune", false, "Remove expired sections previously written by import; alone, only prunes")
 
-	processCmd.Flags().String("profile", "", "Name of the AWS profile")
+	processCmd.Flags().String("profile", "", "Name of the AWS profile (default $AWS_PROFILE; required when not in a terminal)")
+}
+
+// configureCmd defines a Cobra command that generates [profile ...] sections
+// for every account and role available through an sso-session.
+var configureCmd = &cobra.Command{
+	Use:   "configure --sso-session name [--name-template tmpl] [--region region] [--dry-run]",
+	Short: "Discovers SSO accounts and roles and writes profiles for them",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := configureOptions{}
+		opts.session, _ = cmd.Flags().GetString("sso-session")
+		opts.nameTemplate, _ = cmd.Flags().GetString("name-template")
+		opts.region, _ = cmd.Flags().GetString("region")
+		opts.dryRun, _ = cmd.Flags().GetBool("dry-run")
+		return configure(opts)
+	},
 }
 
 // consoleCmd represents a Cobra command to log into AWS Web Console using SSO, opening it in the default browser.
 var consoleCmd = &cobra.Command{
-	Use:   "console --profile [profile-name]",
+	Use:   "console [--profile profile-name]",
 	Short: "Opens the default browser and logs into AWS Web Console using SSO",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		profileName, _ := cmd.Flags().GetString("profile")
-		forceLogout, _ := cmd.Flags().GetBool("force-logout")
-		logoutWait, _ := cmd.Flags().GetInt("logout-wait")
+		flagValue, _ := cmd.Flags().GetString("profile")
+		opts := consoleOptions{}
+		opts.forceLogout, _ = cmd.Flags().GetBool("force-logout")
+		opts.logoutWait, _ = cmd.Flags().GetInt("logout-wait")
+		opts.destination, _ = cmd.Flags().GetString("destination")
+		opts.service, _ = cmd.Flags().GetString("service")
+		opts.region, _ = cmd.Flags().GetString("region")
+		opts.print, _ = cmd.Flags().GetBool("print")
+		opts.copy, _ = cmd.Flags().GetBool("copy")
+		opts.browser, _ = cmd.Flags().GetString("browser")
+		opts.isolation, _ = cmd.Flags().GetString("isolation")
+		opts.sessionDuration, _ = cmd.Flags().GetString("session-duration")
 
-		if profileName == "" {
-			logrus.Error(helper.ErrorPofileSpecification)
-			return errors.New(helper.ErrorPofileSpecification)
+		profileName, err := resolveProfileName(flagValue)
+		if err != nil {
+			return err
 		}
-		return console(profileName, forceLogout, logoutWait)
+		return console(profileName, opts)
 	},
 }
 
 // exportCmd defines a Cobra command to export AWS credentials for a specified profile in a shell-exportable format.
 var exportCmd = &cobra.Command{
-	Use:   "export --profile [profile-name]",
+	Use:   "export [--profile profile-name] [--format sh|fish|powershell|cmd|dotenv|json]",
 	Short: "Prints credentials for exporting to your shell",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		profileName, _ := cmd.Flags().GetString("profile")
-		if profileName == "" {
-			logrus.Error(helper.ErrorPofileSpecification)
-			return errors.New(helper.ErrorPofileSpecification)
+		flagValue, _ := cmd.Flags().GetString("profile")
+		format, _ := cmd.Flags().GetString("format")
+		profileName, err := resolveProfileName(flagValue)
+		if err != nil {
+			return err
 		}
-		return exportCredsToOutput(profileName)
+		return exportCredsToOutput(profileName, format)
 	},
 }
 
-// importCmd defines a Cobra command to fetch AWS credentials for a profile and
-// write them to the local credentials file. The --profile flag is optional:
-// when omitted, the user is prompted to pick an SSO-enabled profile from
-// ~/.aws/config via the package-level defaultSelector.
+// importCmd defines a Cobra command to fetch AWS credentials for one or more
+// profiles and write them to the local credentials file. With a single
+// --profile (or none, in which case the user is prompted to pick an
+// SSO-enabled profile from ~/.aws/config via the package-level
+// defaultSelector) one profile is imported; with --multi and no --profile the
+// user picks several via defaultMultiSelector. Repeating --profile, or using
+// --match / --all, switches to a batch import with a per-profile summary.
+// --prune without any profile selection only prunes expired sections.
 var importCmd = &cobra.Command{
-	Use:   "import [--profile profile-name]",
+	Use:   "import [--profile profile-name]... [--match pattern]... [--all] [--multi] [--force] [--prune]",
 	Short: "Fetches new credentials and writes them to the local credentials file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names, _ := cmd.Flags().GetStringArray("profile")
+		patterns, _ := cmd.Flags().GetStringArray("match")
+		all, _ := cmd.Flags().GetBool("all")
+		multi, _ := cmd.Flags().GetBool("multi")
+		backup, _ := cmd.Flags().GetBool("backup")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		force, _ := cmd.Flags().GetBool("force")
+		prune, _ := cmd.Flags().GetBool("prune")
+		opts := importOptions{backup: backup, concurrency: concurrency, force: force, prune: prune}
+
+		if prune && len(names) == 0 && len(patterns) == 0 && !all && !multi {
+			return pruneCreds(opts)
+		}
+
+		if len(names) > 1 || len(patterns) > 0 || all {
+			profileNames, err := selectImportProfiles(names, patterns, all)
+			if err != nil {
+				return err
+			}
+			return importBatch(profileNames, opts)
+		}
+
+		if len(names) == 0 && multi {
+			profileNames, err := resolveProfileNames()
+			if err != nil {
+				return err
+			}
+			return importBatch(profileNames, opts)
+		}
+
+		flagValue := ""
+		if len(names) == 1 {
+			flagValue = names[0]
+		}
+		profileName, err := resolveProfileName(flagValue)
+		if err != nil {
+			return err
+		}
+		return importCreds(profileName, opts)
+	},
+}
+
+// execCmd defines a Cobra command that runs a child process with the
+// profile's credentials in its environment only.
+var execCmd = &cobra.Command{
+	Use:   "exec [--profile profile-name] -- command [args...]",
+	Short: "Runs a command with credentials injected into its environment",
+	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		flagValue, _ := cmd.Flags().GetString("profile")
 		profileName, err := resolveProfileName(flagValue)
 		if err != nil {
 			return err
 		}
-		return importCreds(profileName)
+		return execWithCreds(profileName, args)
+	},
+}
+
+// serveCmd defines a Cobra command that serves rotating credentials to
+// containers and tools over the container credentials and IMDSv2 protocols.
+var serveCmd = &cobra.Command{
+	Use:   "serve [--profile profile-name] [--listen addr] [--imds [--imds-listen addr]]",
+	Short: "Serves credentials over local container (ECS) and IMDSv2 endpoints",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flagValue, _ := cmd.Flags().GetString("profile")
+		opts := serveOptions{}
+		opts.listen, _ = cmd.Flags().GetString("listen")
+		if imds, _ := cmd.Flags().GetBool("imds"); imds {
+			opts.imdsListen, _ = cmd.Flags().GetString("imds-listen")
+		}
+		profileName, err := resolveProfileName(flagValue)
+		if err != nil {
+			return err
+		}
+		return serve(profileName, opts)
+	},
+}
+
+// statusCmd defines a Cobra command that reports cached credential and SSO
+// token state for one profile or for every SSO profile in the config.
+var statusCmd = &cobra.Command{
+	Use:   "status [--profile profile-name] [--output table|json]",
+	Short: "Shows cached credential and SSO token status",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profileName, _ := cmd.Flags().GetString("profile")
+		format, _ := cmd.Flags().GetString("output")
+		return status(profileName, format)
 	},
 }
 
 // processCmd defines a Cobra command used to fetch and output credential process compatible JSON for a specified profile.
 var processCmd = &cobra.Command{
-	Use:   "process --profile [profile-name]",
+	Use:   "process [--profile profile-name]",
 	Short: "Fetches credential process compatible JSON output",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		profileName, _ := cmd.Flags().GetString("profile")
-		if profileName == "" {
-			logrus.Error(helper.ErrorPofileSpecification)
-			return errors.New(helper.ErrorPofileSpecification)
+		flagValue, _ := cmd.Flags().GetString("profile")
+		profileName, err := resolveProfileName(flagValue)
+		if err != nil {
+			return err
 		}
 		return processCreds(profileName)
 	},
@@ -96,6 +244,8 @@ var versionCmd = &cobra.Command{
 
 // Run initializes the root command for the AWS SSO utility and adds subcommands before executing the CLI application.
 func Run() {
+	// Redact from the start; flags and environment refine this before any command runs.
+	_ = logging.Configure(logrus.StandardLogger(), defaultLogLevel, logging.FormatText)
 	rootCmd := &cobra.Command{
 		Use:   "aws-sso-login",
 		Short: "AWS SSO utility",
@@ -106,8 +256,27 @@ func Run() {
 		// rendering below.
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return configureLogging(cmd)
+		},
 	}
-	rootCmd.AddCommand(consoleCmd, exportCmd, importCmd, processCmd, versionCmd)
+	rootCmd.PersistentFlags().StringVar(&helper.ConfigFileFlag, "config-file", "",
+		"AWS config file (default $AWS_CONFIG_FILE or ~/.aws/config)")
+	rootCmd.PersistentFlags().StringVar(&helper.CredentialsFileFlag, "credentials-file", "",
+		"AWS shared credentials file (default $AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials)")
+	rootCmd.PersistentFlags().StringVar(&helper.CliCacheDirFlag, "cli-cache-dir", "",
+		"AWS CLI credentials cache directory (default $AWS_SSO_LOGIN_CLI_CACHE_DIR or ~/.aws/cli/cache)")
+	rootCmd.PersistentFlags().StringVar(&helper.SSOCacheDirFlag, "sso-cache-dir", "",
+		"SSO token cache directory (default $AWS_SSO_LOGIN_SSO_CACHE_DIR or ~/.aws/sso/cache)")
+	rootCmd.PersistentFlags().StringVar(&credentialSourceFlag, "credential-source", "",
+		"Where role credentials come from: native or awscli (default native)")
+	rootCmd.PersistentFlags().StringVar(&refreshWindowFlag, "refresh-window", "",
+		"Refresh cached credentials that expire within this duration, e.g. 15m")
+	rootCmd.PersistentFlags().StringVar(&logLevelFlag, "log-level", "",
+		"Log level: trace, debug, info, warn or error (default $AWS_SSO_LOGIN_LOG_LEVEL, else info; warn for process)")
+	rootCmd.PersistentFlags().StringVar(&logFormatFlag, "log-format", "",
+		"Log format: text or json (default $AWS_SSO_LOGIN_LOG_FORMAT, else text)")
+	rootCmd.AddCommand(configureCmd, consoleCmd, execCmd, exportCmd, importCmd, processCmd, serveCmd, statusCmd, versionCmd)
 	if err := rootCmd.Execute(); err != nil {
 		reportAndExit(err)
 	}
@@ -115,7 +284,12 @@ func Run() {
 
 // reportAndExit prints a single concise message for known failure modes and
 // exits with status 1. Detailed/verbose context is left to debug-level logs.
+// A failed exec child is not reported; its exit status is propagated as is.
 func reportAndExit(err error) {
+	var exitErr *childExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.code)
+	}
 	if errors.Is(err, errSSORoleNoAccess) {
 		logrus.Errorf("No access: the configured SSO role is not assigned to your user. "+
 			"Ask your AWS administrator to grant access. (%v)", err)
@@ -1,29 +1,29 @@
 package cli
 
 import (
-	"bytes"
-	"crypto/sha1"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-ini/ini"
 	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/fsutil"
 	"github.com/witnsby/aws-sso-login/src/internal/helper"
+	"github.com/witnsby/aws-sso-login/src/internal/inifile"
 	"github.com/witnsby/aws-sso-login/src/internal/model"
+	"github.com/witnsby/aws-sso-login/src/internal/profiles"
+	"github.com/witnsby/aws-sso-login/src/internal/redact"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
-	"os/user"
 	"path/filepath"
-	"strings"
+	"strconv"
 	"time"
 )
 
 // errSSORoleNoAccess indicates that the AWS SSO identity is authenticated but
 // the configured role is not assigned to the user (e.g. AWS SSO returned
-// ForbiddenException / AccessDeniedException). Re-running `aws sso login`
+// ForbiddenException / AccessDeniedException). Logging in again
 // cannot resolve this; the user must obtain access from an administrator.
 var errSSORoleNoAccess = errors.New("no access to the configured SSO role")
 
@@ -33,44 +33,44 @@ var errSSORoleNoAccess = errors.New("no access to the configured SSO role")
 type awsCredentialsManager struct {
 	profileName     string
 	credentialsPath string
-	credsFile       *ini.File
+	credsFile       *inifile.File
 	roleCred        *model.RoleCredential
 	profile         *ini.Section
 	region          string
 	account         string
 	signinToken     string
+	destination     string
+	partition       helper.Partition
+	backupCreds     bool
+	pruneExpired    bool
+	// minRefreshWindow makes the credential source refresh credentials that
+	// expire within it, even if the configured refresh window is shorter.
+	minRefreshWindow time.Duration
 }
 
 // retrieveAndSetProfile retrieves an AWS profile, fetches role credentials, and sets them for the credentials manager.
 // On a recoverable failure (e.g. expired SSO token) it performs a single
-// `aws sso login` attempt and retries credential retrieval once. On an
+// SSO login attempt and retries credential retrieval once. On an
 // unrecoverable failure (the SSO role is not assigned to the user, surfaced
 // as errSSORoleNoAccess) it returns immediately without invoking the login
-// flow, to avoid an infinite re-prompt loop.
+// flow, to avoid an infinite re-prompt loop. A failed AssumeRole for a
+// chained profile (errAssumeRoleFailed) is returned immediately too.
 func (m *awsCredentialsManager) retrieveAndSetProfile() error {
-	profile, err := retrieveProfile(m.profileName)
-	if err != nil {
-		logrus.Info(err)
+	err := m.refreshRoleCredentials()
+	if err == nil || m.profile == nil {
 		return err
 	}
-	m.profile = profile
 
-	roleCred, err := getRoleCredentials(m.profileName, m.profile, false)
-	if err == nil {
-		m.roleCred = roleCred
-		return nil
-	}
-
-	if errors.Is(err, errSSORoleNoAccess) {
+	if errors.Is(err, errSSORoleNoAccess) || errors.Is(err, errAssumeRoleFailed) {
 		return err
 	}
 
-	logrus.Infof("Credentials for profile [%s] not available, attempting `aws sso login`...", m.profileName)
+	logrus.Infof("Credentials for profile [%s] not available, attempting SSO login...", m.profileName)
 	if loginErr := m.performSSOLogin(); loginErr != nil {
 		return loginErr
 	}
 
-	roleCred, err = getRoleCredentials(m.profileName, m.profile, false)
+	roleCred, err := getRoleCredentialsWithin(m.profileName, m.profile, m.minRefreshWindow)
 	if err != nil {
 		return err
 	}
@@ -78,123 +78,98 @@ func (m *awsCredentialsManager) retrieveAndSetProfile() error {
 	return nil
 }
 
-// performSSOLogin runs the AWS CLI SSO login command for the specified profile and returns an error if the command fails.
-func (m *awsCredentialsManager) performSSOLogin() error {
-	cmd := exec.Command("aws", "sso", "login", "--profile", m.profileName)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
+// refreshRoleCredentials loads the profile and fetches its role credentials
+// without ever starting an SSO login; a missing or expired SSO token is
+// returned as an error wrapping errSSOLoginRequired. m.profile is left nil
+// when the profile itself cannot be loaded.
+func (m *awsCredentialsManager) refreshRoleCredentials() error {
+	m.profile = nil
+	profile, err := retrieveProfile(m.profileName)
 	if err != nil {
-		return fmt.Errorf("failed to execute aws sso login: %v - %s", err, stderr.String())
+		logrus.Info(err)
+		return err
 	}
+	m.profile = profile
 
+	roleCred, err := getRoleCredentialsWithin(m.profileName, m.profile, m.minRefreshWindow)
+	if err != nil {
+		return err
+	}
+	m.roleCred = roleCred
 	return nil
 }
 
-// getRoleCredentials either reads from the local AWS CLI cache or triggers
-// the AWS CLI to refresh the cache by calling `aws sts get-caller-identity`.
-func getRoleCredentials(profileName string, profile *ini.Section, silent bool) (*model.RoleCredential, error) {
-	// Try reading from the CLI cache
-	roleCred, err := getCachedRoleCredentials(profile)
-	if err == nil && roleCred != nil {
-		// If credentials are unexpired, return them
-		if !isExpired(roleCred.Expiration) {
-			return roleCred, nil
-		}
-		// Otherwise, we'll refresh
+// performSSOLogin runs the native SSO OIDC device authorization flow for the profile.
+func (m *awsCredentialsManager) performSSOLogin() error {
+	if err := runSSOLogin(m.profile); err != nil {
+		return fmt.Errorf("failed to perform SSO login for profile %s: %w", m.profileName, err)
 	}
+	return nil
+}
 
-	// If we couldn't read them, or they're expired, attempt to refresh
-	if err = updateCachedRoleCredentials(profileName, silent); err != nil {
-		return nil, err
-	}
+// getRoleCredentials returns role credentials for the profile from the
+// CredentialSource selected for it (see resolveCredentialSource).
+func getRoleCredentials(profileName string, profile *ini.Section) (*model.RoleCredential, error) {
+	return getRoleCredentialsWithin(profileName, profile, 0)
+}
 
-	// Then try again
-	roleCred, err = getCachedRoleCredentials(profile)
+// getRoleCredentialsWithin is getRoleCredentials for callers that need
+// credentials to outlive minWindow. Sources that cannot refresh early
+// (see earlyRefresher) ignore it.
+func getRoleCredentialsWithin(profileName string, profile *ini.Section, minWindow time.Duration) (*model.RoleCredential, error) {
+	source, err := resolveCredentialSource(profile)
 	if err != nil {
 		return nil, err
 	}
-	if roleCred == nil {
-		return nil, fmt.Errorf("could not retrieve credentials for '%s'", profileName)
+	var roleCred *model.RoleCredential
+	if early, ok := source.(earlyRefresher); ok && minWindow > 0 {
+		roleCred, err = early.RetrieveWithin(profileName, profile, minWindow)
+	} else {
+		roleCred, err = source.Retrieve(profileName, profile)
 	}
-	// Final check
-	if isExpired(roleCred.Expiration) {
-		return nil, fmt.Errorf("credentials for '%s' are expired", profileName)
+	if err != nil {
+		return nil, err
 	}
+	redact.Register(roleCred.AccessKeyId, roleCred.SecretAccessKey, roleCred.SessionToken)
 	return roleCred, nil
 }
 
-// updateCachedRoleCredentials calls `aws sts get-caller-identity --profile=XYZ`.
-// This triggers the AWS CLI's SSO logic to refresh ~/.aws/cli/cache.
+// updateCachedRoleCredentials fetches fresh role credentials with the cached
+// SSO access token, or by assuming role_arn for a chained profile, and writes
+// them to ~/.aws/cli/cache, where both this tool and the AWS CLI pick them up.
 //
-// If the AWS CLI exits with a "no access" condition (ForbiddenException /
-// AccessDeniedException returned by the SSO GetRoleCredentials API), the
-// returned error wraps errSSORoleNoAccess so callers can distinguish it from
-// transient/expired-token failures.
-func updateCachedRoleCredentials(profileName string, silent bool) error {
-	cmd := exec.Command("aws", "sts", "get-caller-identity",
-		"--query", "Arn", "--output", "text", "--profile", profileName)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	output, err := cmd.Output()
+// If the SSO portal reports that the user has no access to the role, the
+// returned error wraps errSSORoleNoAccess; a missing or expired SSO token
+// wraps errSSOLoginRequired.
+func updateCachedRoleCredentials(profileName string, profile *ini.Section) error {
+	fetch := fetchRoleCredentials
+	if isChainedProfile(profile) {
+		fetch = assumeRoleCredentials
+	}
+	roleCred, err := fetch(profileName, profile)
 	if err != nil {
-		stderrStr := strings.TrimSpace(stderr.String())
-		if stderrStr != "" {
-			logrus.Debug(stderrStr)
-		}
-		if isSSORoleNoAccessStderr(stderrStr) {
-			return fmt.Errorf("%w for profile %q", errSSORoleNoAccess, profileName)
-		}
-		return fmt.Errorf("please login with 'aws sso login --profile=%s'", profileName)
+		return err
 	}
-	if !silent {
-		fmt.Printf("Updated credentials for: %s\n", string(output))
+	if err := writeCachedRoleCredentials(profile, roleCred); err != nil {
+		return fmt.Errorf("failed to cache credentials for profile %s: %w", profileName, err)
 	}
+	logrus.Infof("Updated credentials for: %s", profileName)
 	return nil
 }
 
-// isSSORoleNoAccessStderr returns true when the AWS CLI stderr indicates the
-// authenticated user has no access to the configured SSO role. These cases
-// cannot be resolved by re-running `aws sso login`.
-func isSSORoleNoAccessStderr(stderr string) bool {
-	if stderr == "" {
-		return false
-	}
-	lower := strings.ToLower(stderr)
-	return strings.Contains(lower, "forbiddenexception") ||
-		strings.Contains(lower, "accessdeniedexception") ||
-		strings.Contains(lower, "no access")
-}
-
+// buildCacheFilePath returns the ~/.aws/cli/cache file the AWS CLI uses for
+// the profile's role credentials (see cliCacheKey).
 func buildCacheFilePath(profile *ini.Section) (string, error) {
 	cachePath, err := helper.GetAwsCliCachePath()
 	if err != nil {
 		return "", err
 	}
-
-	// Build a cache key (sha1 of sorted JSON: {startUrl, roleName, accountId})
-	startURL := profile.Key("sso_start_url").String()
-	roleName := profile.Key("sso_role_name").String()
-	accountID := profile.Key("sso_account_id").String()
-
-	args := map[string]string{
-		"startUrl":  startURL,
-		"roleName":  roleName,
-		"accountId": accountID,
-	}
-
-	b, _ := json.Marshal(args)
-	h := sha1.New()
-	h.Write(b)
-	cacheKey := fmt.Sprintf("%x", h.Sum(nil))
-
-	// Construct the path
-	return filepath.Join(cachePath, cacheKey+".json"), nil
+	return filepath.Join(cachePath, cliCacheKey(profile)+".json"), nil
 }
 
-// getCachedRoleCredentials looks up ~/.aws/cli/cache/<sha1>.json
+// getCachedRoleCredentials looks up ~/.aws/cli/cache/<sha1>.json. When that
+// file does not exist, the cache directory is scanned for an entry whose
+// contents identify the profile's role (see scanCachedRoleCredentials).
 func getCachedRoleCredentials(profile *ini.Section) (*model.RoleCredential, error) {
 	fullPath, err := buildCacheFilePath(profile)
 	if err != nil {
@@ -203,6 +178,11 @@ func getCachedRoleCredentials(profile *ini.Section) (*model.RoleCredential, erro
 	}
 	// Attempt to read
 	data, err := os.ReadFile(fullPath)
+	if os.IsNotExist(err) {
+		if roleCred := scanCachedRoleCredentials(filepath.Dir(fullPath), profile); roleCred != nil {
+			return roleCred, nil
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -217,13 +197,43 @@ func getCachedRoleCredentials(profile *ini.Section) (*model.RoleCredential, erro
 	return &raw.Credentials, nil
 }
 
+// writeCachedRoleCredentials stores roleCred in ~/.aws/cli/cache/<sha1>.json
+// using the same envelope the AWS CLI writes for SSO or assumed-role credentials.
+func writeCachedRoleCredentials(profile *ini.Section, roleCred *model.RoleCredential) error {
+	fullPath, err := buildCacheFilePath(profile)
+	if err != nil {
+		return err
+	}
+	raw := struct {
+		ProviderType string               `json:"ProviderType,omitempty"`
+		Credentials  model.RoleCredential `json:"Credentials"`
+	}{
+		Credentials: *roleCred,
+	}
+	if !isChainedProfile(profile) {
+		raw.ProviderType = "sso"
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	// Written atomically: the AWS CLI and parallel batch workers may read the
+	// entry while it is being replaced.
+	return fsutil.WriteFileAtomic(fullPath, data, 0o600)
+}
+
 func isExpired(expirationTime string) bool {
+	return expiresWithin(expirationTime, 0)
+}
+
+// expiresWithin reports whether the credentials expire within window from now.
+func expiresWithin(expirationTime string, window time.Duration) bool {
 	parsedTime, err := parseExpirationTime(expirationTime)
 	if err != nil {
 		// If parsing fails, assume it's expired so user can refresh
 		return true
 	}
-	return time.Now().After(parsedTime)
+	return time.Now().Add(window).After(parsedTime)
 }
 
 // parseExpirationTime attempts to parse a string into a time.Time using multiple layouts
@@ -237,11 +247,12 @@ func parseExpirationTime(expirationTime string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("unable to parse expiration time")
 }
 
-// getSigninToken obtains a federation sign-in token from AWS Federation endpoint
-func getSigninToken(rc *model.RoleCredential) (string, error) {
+// getSigninToken obtains a federation sign-in token from the partition's federation endpoint
+// for a console session lasting sessionDuration.
+func getSigninToken(rc *model.RoleCredential, partition helper.Partition, sessionDuration time.Duration) (string, error) {
 	params := url.Values{}
 	params.Set("Action", "getSigninToken")
-	params.Set("SessionDuration", helper.SessionDuration)
+	params.Set("SessionDuration", strconv.Itoa(int(sessionDuration.Seconds())))
 	sess := map[string]string{
 		"sessionId":    rc.AccessKeyId,
 		"sessionKey":   rc.SecretAccessKey,
@@ -250,22 +261,27 @@ func getSigninToken(rc *model.RoleCredential) (string, error) {
 	sessB, _ := json.Marshal(sess)
 	params.Set("Session", string(sessB))
 
-	fedURL := "https://signin.aws.amazon.com/federation?" + params.Encode()
+	fedURL := partition.FederationURL() + "?" + params.Encode()
 
-	out, err := getURL(fedURL)
+	out, err := federationGet(fedURL)
 	if err != nil {
-		return "", err
+		// The request URL carries the credentials in its Session parameter.
+		return "", redact.Errorf("requesting console sign-in token: %w", err)
 	}
 
 	var resp struct {
 		SigninToken string `json:"SigninToken"`
 	}
 	if err := json.Unmarshal(out, &resp); err != nil {
-		return "", err
+		return "", fmt.Errorf("decoding console sign-in token: %w", err)
 	}
+	redact.Register(resp.SigninToken)
 	return resp.SigninToken, nil
 }
 
+// federationGet fetches a federation endpoint URL.
+var federationGet = getURL
+
 func getURL(raw string) ([]byte, error) {
 	// Perform an HTTP GET request
 	resp, err := http.Get(raw)
@@ -290,10 +306,13 @@ func getURL(raw string) ([]byte, error) {
 }
 
 // retrieveProfile retrieves and validates an AWS profile from the configuration file.
-// It ensures the specified profile contains all required attributes for AWS SSO workflows.
+// It ensures the specified profile contains all required attributes for AWS SSO workflows,
+// resolving sso_start_url and sso_region through sso_session when the profile uses one.
+// For a chained profile (role_arn with source_profile) the SSO attributes are
+// checked on the base of the chain and its connection settings are copied in.
 func retrieveProfile(profileName string) (*ini.Section, error) {
 	// Get the path to the AWS configuration file.
-	configPath, err := GetAwsConfigPath()
+	configPath, err := helper.GetAwsConfigPath()
 	if err != nil {
 		logrus.Infof("Cannot locate AWS config file, using default path: %s", configPath)
 		return nil, err
@@ -316,12 +335,30 @@ func retrieveProfile(profileName string) (*ini.Section, error) {
 		return nil, fmt.Errorf("cannot find profile [%s] in %s", sectionName, configPath)
 	}
 
+	// A chained profile takes its SSO settings from the base of its
+	// source_profile chain; its account and role come from role_arn.
+	ssoSection := section
+	if isChainedProfile(section) {
+		if _, _, err := profiles.ParseRoleARN(section.Key("role_arn").String()); err != nil {
+			return nil, fmt.Errorf("invalid profile %s: %w", profileName, err)
+		}
+		if ssoSection, err = profiles.ResolveSourceProfile(configFile, section); err != nil {
+			return nil, fmt.Errorf("invalid profile %s: %w", profileName, err)
+		}
+	} else if err := profiles.ResolveSSOSession(configFile, section); err != nil {
+		// Pull sso_start_url and sso_region from a referenced [sso-session] section.
+		return nil, fmt.Errorf("invalid profile %s: %w", profileName, err)
+	}
+
 	// List of required keys for the profile section.
 	requiredKeys := []string{"sso_start_url", "sso_account_id", "sso_role_name", "sso_region"}
 
 	// Validate if all required keys are present in the profile section.
 	for _, key := range requiredKeys {
-		if val := section.Key(key).String(); val == "" {
+		if val := ssoSection.Key(key).String(); val == "" {
+			if ssoSection != section {
+				return nil, fmt.Errorf("missing required attribute %q in [%s], the source of profile %s", key, ssoSection.Name(), profileName)
+			}
 			return nil, fmt.Errorf("missing required attribute %q in profile %s", key, profileName)
 		}
 	}
@@ -329,30 +366,3 @@ func retrieveProfile(profileName string) (*ini.Section, error) {
 	// Return the valid profile section.
 	return section, nil
 }
-
-// GetAwsConfigPath determines the file path of the AWS configuration file.
-//
-// It checks the following sources in order of priority:
-//  1. If the "AWS_CONFIG_FILE" environment variable is set, its value is returned.
-//  2. If the environment variable is not set, it retrieves the current user's home directory
-//     and constructs the default AWS configuration file path: `<HomeDirectory>/.aws/config`.
-//
-// Returns:
-// - The file path as a string if successful.
-// - An error if there is an issue retrieving the current user or resolving the path.
-func GetAwsConfigPath() (string, error) {
-	// Check if the AWS_CONFIG_FILE environment variable is set.
-	if configPath := os.Getenv("AWS_CONFIG_FILE"); configPath != "" {
-		return configPath, nil
-	}
-
-	// Retrieve the current user's home directory.
-	currentUser, err := user.Current()
-	if err != nil {
-		return "", fmt.Errorf("unable to retrieve current user: %w", err)
-	}
-
-	// Construct the default AWS configuration file path.
-	defaultConfigPath := filepath.Join(currentUser.HomeDir, ".aws", "config")
-	return defaultConfigPath, nil
-}
@@ -2,51 +2,57 @@ package cli
 
 import (
 	"errors"
+	"path/filepath"
 	"testing"
+
+	"github.com/go-ini/ini"
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
 )
 
-func TestIsSSORoleNoAccessStderr(t *testing.T) {
+func TestClassifySSOError(t *testing.T) {
 	cases := []struct {
-		name   string
-		stderr string
-		want   bool
+		name string
+		err  error
+		want error
 	}{
 		{
-			name:   "empty",
-			stderr: "",
-			want:   false,
-		},
-		{
-			name:   "forbidden exception from GetRoleCredentials",
-			stderr: "An error occurred (ForbiddenException) when calling the GetRoleCredentials operation: No access",
-			want:   true,
+			name: "forbidden exception from GetRoleCredentials",
+			err:  &sso.APIError{StatusCode: 403, Code: "ForbiddenException", Message: "No access"},
+			want: errSSORoleNoAccess,
 		},
 		{
-			name:   "access denied exception",
-			stderr: "An error occurred (AccessDeniedException) when calling the AssumeRoleWithSAML operation",
-			want:   true,
+			name: "access denied exception",
+			err:  &sso.APIError{StatusCode: 403, Code: "AccessDeniedException"},
+			want: errSSORoleNoAccess,
 		},
 		{
-			name:   "lowercase no access phrase",
-			stderr: "request failed: no access for the requested resource",
-			want:   true,
+			name: "unauthorized means the token is no longer valid",
+			err:  &sso.APIError{StatusCode: 401, Code: "UnauthorizedException", Message: "Session token not found or invalid"},
+			want: errSSOLoginRequired,
 		},
 		{
-			name:   "invalid grant is not no-access",
-			stderr: "An error occurred (InvalidGrantException) when calling the CreateToken operation",
-			want:   false,
+			name: "invalid grant is not no-access",
+			err:  &sso.APIError{StatusCode: 400, Code: "InvalidGrantException"},
+			want: errSSOLoginRequired,
 		},
 		{
-			name:   "unrelated network error",
-			stderr: "Could not connect to the endpoint URL",
-			want:   false,
+			name: "unrelated network error passes through",
+			err:  errors.New("Could not connect to the endpoint URL"),
+			want: nil,
 		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			if got := isSSORoleNoAccessStderr(tc.stderr); got != tc.want {
-				t.Fatalf("isSSORoleNoAccessStderr(%q) = %v, want %v", tc.stderr, got, tc.want)
+			got := classifySSOError("p", tc.err)
+			if tc.want == nil {
+				if errors.Is(got, errSSORoleNoAccess) || errors.Is(got, errSSOLoginRequired) {
+					t.Fatalf("classifySSOError(%v) = %v, want unclassified error", tc.err, got)
+				}
+				return
+			}
+			if !errors.Is(got, tc.want) {
+				t.Fatalf("classifySSOError(%v) = %v, want wrapped %v", tc.err, got, tc.want)
 			}
 		})
 	}
@@ -54,7 +60,7 @@ func TestIsSSORoleNoAccessStderr(t *testing.T) {
 
 // TestErrSSORoleNoAccess_IsWrappable ensures the sentinel survives wrapping
 // with fmt.Errorf("...: %w", errSSORoleNoAccess), which is how
-// updateCachedRoleCredentials emits it. retrieveAndSetProfile relies on
+// classifySSOError emits it. retrieveAndSetProfile relies on
 // errors.Is to short-circuit the login retry loop.
 func TestErrSSORoleNoAccess_IsWrappable(t *testing.T) {
 	wrapped := wrapNoAccess()
@@ -66,3 +72,73 @@ func TestErrSSORoleNoAccess_IsWrappable(t *testing.T) {
 func wrapNoAccess() error {
 	return errors.Join(errors.New("profile \"x\""), errSSORoleNoAccess)
 }
+
+// TestBuildCacheFilePath verifies the cache key matches the AWS CLI v2
+// derivation for both legacy and sso-session profiles.
+func TestBuildCacheFilePath(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name: "legacy profile hashes startUrl",
+			config: `[profile p]
+sso_start_url = https://example.awsapps.com/start
+sso_account_id = 123456789012
+sso_role_name = Admin
+`,
+			want: sha1Hex(`{"accountId":"123456789012","roleName":"Admin","startUrl":"https://example.awsapps.com/start"}`),
+		},
+		{
+			name: "sso-session profile hashes sessionName",
+			config: `[profile p]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Admin
+`,
+			want: sha1Hex(`{"accountId":"123456789012","roleName":"Admin","sessionName":"corp"}`),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := ini.Load([]byte(tc.config))
+			if err != nil {
+				t.Fatalf("load fixture: %v", err)
+			}
+			got, err := buildCacheFilePath(cfg.Section("profile p"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if filepath.Base(got) != tc.want+".json" {
+				t.Fatalf("cache file = %q, want %q", filepath.Base(got), tc.want+".json")
+			}
+		})
+	}
+}
+
+// TestRetrieveProfile_SSOSession verifies that profiles referencing an
+// [sso-session] section pass validation with the session's start URL/region.
+func TestRetrieveProfile_SSOSession(t *testing.T) {
+	writeAwsConfig(t, `[profile corp-admin]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Admin
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+sso_region = eu-west-1
+`)
+
+	section, err := retrieveProfile("corp-admin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := section.Key("sso_region").String(); got != "eu-west-1" {
+		t.Fatalf("sso_region = %q, want %q", got, "eu-west-1")
+	}
+	if got := section.Key("sso_start_url").String(); got != "https://example.awsapps.com/start" {
+		t.Fatalf("sso_start_url = %q, want session start URL", got)
+	}
+}
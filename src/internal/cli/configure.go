@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/go-ini/ini"
+	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/fsutil"
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+	"github.com/witnsby/aws-sso-login/src/internal/inifile"
+	"github.com/witnsby/aws-sso-login/src/internal/textdiff"
+)
+
+// defaultProfileNameTemplate names generated profiles when --name-template is not set.
+const defaultProfileNameTemplate = "{{.AccountName}}-{{.RoleName}}"
+
+// configureOutput receives the --dry-run diff.
+var configureOutput io.Writer = os.Stdout
+
+// configureOptions controls profile generation.
+type configureOptions struct {
+	// session is the [sso-session <name>] whose accounts and roles are discovered.
+	session string
+	// nameTemplate is a text/template rendering each profile name.
+	nameTemplate string
+	// region, when set, is written as the region of every generated profile.
+	region string
+	// dryRun prints a diff instead of writing the config file.
+	dryRun bool
+}
+
+// profileNameData is the data passed to the profile name template.
+type profileNameData struct {
+	AccountName string
+	AccountID   string
+	RoleName    string
+	SessionName string
+}
+
+// generatedProfile is one [profile ...] section configure writes.
+type generatedProfile struct {
+	name      string
+	accountID string
+	roleName  string
+}
+
+// configure discovers every account and role available through an
+// sso-session and writes a profile for each to the AWS config file.
+//
+// Existing [profile ...] sections with a generated name are updated in place:
+// only sso_session, sso_account_id, sso_role_name (and region with --region)
+// are set, so other keys, comments and unrelated sections are left untouched.
+// Profiles for roles that are no longer assigned are not removed. With
+// --dry-run a unified diff of the change is printed instead.
+func configure(opts configureOptions) error {
+	if opts.session == "" {
+		return errors.New("--sso-session is required")
+	}
+	if opts.nameTemplate == "" {
+		opts.nameTemplate = defaultProfileNameTemplate
+	}
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(opts.nameTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid --name-template: %w", err)
+	}
+
+	configPath, err := helper.GetAwsConfigPath()
+	if err != nil {
+		return fmt.Errorf("could not determine AWS config path: %w", err)
+	}
+	if !opts.dryRun {
+		unlock, err := fsutil.Lock(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to lock %s: %w", configPath, err)
+		}
+		defer func() { _ = unlock() }()
+	}
+
+	original, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read AWS config file %s: %w", configPath, err)
+	}
+	session, err := ssoSessionSection(original, opts.session)
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, configPath)
+	}
+
+	generated, err := discoverProfiles(session, opts.session, tmpl)
+	if err != nil {
+		return err
+	}
+
+	file := inifile.Parse(original)
+	for _, p := range generated {
+		section := "profile " + p.name
+		file.Set(section, "sso_session", opts.session)
+		file.Set(section, "sso_account_id", p.accountID)
+		file.Set(section, "sso_role_name", p.roleName)
+		if opts.region != "" {
+			file.Set(section, "region", opts.region)
+		}
+	}
+	updated := file.Bytes()
+
+	if opts.dryRun {
+		diff := textdiff.Unified(configPath, configPath, original, updated)
+		if diff == "" {
+			logrus.Infof("%s is up to date (%d profiles)", configPath, len(generated))
+			return nil
+		}
+		_, err := io.WriteString(configureOutput, diff)
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(configPath, updated, 0o600); err != nil {
+		return fmt.Errorf("failed to write AWS config file %s: %w", configPath, err)
+	}
+	logrus.Infof("Configured %d profiles for sso-session %s in %s", len(generated), opts.session, configPath)
+	return nil
+}
+
+// ssoSessionSection returns a section describing the named sso-session with
+// the sso_session key set, suitable for ssoLogin and loadSSOAccessToken.
+func ssoSessionSection(config []byte, name string) (*ini.Section, error) {
+	cfg, err := ini.Load(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AWS config: %w", err)
+	}
+	session, err := cfg.GetSection("sso-session " + name)
+	if err != nil {
+		return nil, fmt.Errorf("no [sso-session %s] section", name)
+	}
+	for _, key := range []string{"sso_start_url", "sso_region"} {
+		if session.Key(key).String() == "" {
+			return nil, fmt.Errorf("missing %s in [sso-session %s]", key, name)
+		}
+	}
+	session.Key("sso_session").SetValue(name)
+	return session, nil
+}
+
+// discoverProfiles lists the session's accounts and roles through the SSO
+// portal, logging in first if no valid token is cached, and renders a
+// profile name for each role. Names are sorted and must be unique.
+func discoverProfiles(session *ini.Section, sessionName string, tmpl *template.Template) ([]generatedProfile, error) {
+	label := "sso-session " + sessionName
+	accessToken, err := loadSSOAccessToken(label, session)
+	if errors.Is(err, errSSOLoginRequired) {
+		logrus.Infof("No valid SSO token for %s, attempting SSO login...", label)
+		if err := runSSOLogin(session); err != nil {
+			return nil, fmt.Errorf("failed to perform SSO login for %s: %w", label, err)
+		}
+		accessToken, err = loadSSOAccessToken(label, session)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	client := newSSOClient(session.Key("sso_region").String())
+	accounts, err := client.ListAccounts(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	var generated []generatedProfile
+	owner := map[string]string{}
+	for _, account := range accounts {
+		roles, err := client.ListAccountRoles(ctx, accessToken, account.AccountID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list roles for account %s: %w", account.AccountID, err)
+		}
+		for _, role := range roles {
+			name, err := renderProfileName(tmpl, profileNameData{
+				AccountName: account.AccountName,
+				AccountID:   account.AccountID,
+				RoleName:    role.RoleName,
+				SessionName: sessionName,
+			})
+			if err != nil {
+				return nil, err
+			}
+			id := account.AccountID + "/" + role.RoleName
+			if prev, ok := owner[name]; ok {
+				return nil, fmt.Errorf("profile name %q is generated for both %s and %s; include {{.AccountID}} in --name-template", name, prev, id)
+			}
+			owner[name] = id
+			generated = append(generated, generatedProfile{name: name, accountID: account.AccountID, roleName: role.RoleName})
+		}
+	}
+	sort.Slice(generated, func(i, j int) bool { return generated[i].name < generated[j].name })
+	return generated, nil
+}
+
+// renderProfileName executes the name template, replaces runs of whitespace
+// with "-" and rejects names that cannot be used in a section header.
+func renderProfileName(tmpl *template.Template, data profileNameData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid --name-template: %w", err)
+	}
+	name := strings.Join(strings.Fields(b.String()), "-")
+	if name == "" || strings.ContainsAny(name, "[]") {
+		return "", fmt.Errorf("--name-template produced an invalid profile name %q for %s/%s", name, data.AccountID, data.RoleName)
+	}
+	return name, nil
+}
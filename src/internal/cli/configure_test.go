@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
+)
+
+const configureFixture = `# managed by hand
+[default]
+region = eu-west-1
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+
+; existing profile keeps its extra keys
+[profile Dev-ReadOnly]
+sso_session = corp
+sso_account_id = 111111111111
+sso_role_name = ReadOnly
+output = json
+`
+
+// fakeAssignmentPortal serves ListAccounts and ListAccountRoles for two
+// accounts and points newSSOClient at it for the duration of the test.
+func fakeAssignmentPortal(t *testing.T) {
+	t.Helper()
+	roles := map[string][]string{
+		"111111111111": {"ReadOnly", "Admin"},
+		"222222222222": {"ReadOnly"},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-amz-sso_bearer_token") != "access-token" {
+			w.Header().Set("x-amzn-ErrorType", "UnauthorizedException")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/assignment/accounts":
+			_ = json.NewEncoder(w).Encode(map[string]any{"accountList": []map[string]string{
+				{"accountId": "111111111111", "accountName": "Dev"},
+				{"accountId": "222222222222", "accountName": "Prod Env"},
+			}})
+		case "/assignment/roles":
+			id := r.URL.Query().Get("account_id")
+			var list []map[string]string
+			for _, name := range roles[id] {
+				list = append(list, map[string]string{"accountId": id, "roleName": name})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"roleList": list})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	orig := newSSOClient
+	t.Cleanup(func() { newSSOClient = orig })
+	newSSOClient = func(string) *sso.Client {
+		return &sso.Client{OIDCEndpoint: srv.URL, PortalEndpoint: srv.URL, HTTPClient: srv.Client()}
+	}
+}
+
+// setupConfigure writes the config fixture, caches an SSO token for the corp
+// session and starts the fake portal. It returns the config path.
+func setupConfigure(t *testing.T) string {
+	t.Helper()
+	configPath := writeAwsConfig(t, configureFixture)
+	dir := isolateAwsPaths(t)
+	token := &sso.CachedToken{AccessToken: "access-token", ExpiresAt: time.Now().Add(time.Hour).UTC().Format(sso.TimeFormat)}
+	if err := sso.SaveToken(filepath.Join(dir, "sso", "cache"), sso.TokenCacheKey("corp", ""), token); err != nil {
+		t.Fatalf("save token: %v", err)
+	}
+	fakeAssignmentPortal(t)
+	return configPath
+}
+
+func TestConfigure_WritesProfilesAndPreservesConfig(t *testing.T) {
+	configPath := setupConfigure(t)
+
+	if err := configure(configureOptions{session: "corp", region: "eu-central-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	out := string(got)
+	if !strings.HasPrefix(out, configureFixture[:strings.Index(configureFixture, "[profile")]) {
+		t.Fatalf("expected leading sections and comments untouched, got:\n%s", out)
+	}
+	for _, want := range []string{
+		"; existing profile keeps its extra keys\n[profile Dev-ReadOnly]\n",
+		"output = json\n",
+		"[profile Dev-Admin]\nsso_session = corp\nsso_account_id = 111111111111\nsso_role_name = Admin\nregion = eu-central-1\n",
+		"[profile Prod-Env-ReadOnly]\nsso_session = corp\nsso_account_id = 222222222222\nsso_role_name = ReadOnly\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected config to contain %q, got:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "[profile Dev-ReadOnly]"); n != 1 {
+		t.Errorf("expected existing profile to be updated in place, found %d sections", n)
+	}
+
+	// A second run has nothing left to change.
+	var buf bytes.Buffer
+	orig := configureOutput
+	t.Cleanup(func() { configureOutput = orig })
+	configureOutput = &buf
+	if err := configure(configureOptions{session: "corp", region: "eu-central-1", dryRun: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no diff on second run, got:\n%s", buf.String())
+	}
+}
+
+func TestConfigure_DryRunPrintsDiff(t *testing.T) {
+	configPath := setupConfigure(t)
+	var buf bytes.Buffer
+	orig := configureOutput
+	t.Cleanup(func() { configureOutput = orig })
+	configureOutput = &buf
+
+	err := configure(configureOptions{session: "corp", nameTemplate: "{{.AccountID}}_{{.RoleName}}", dryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	diff := buf.String()
+	for _, want := range []string{"+[profile 111111111111_Admin]", "+[profile 222222222222_ReadOnly]", "+sso_role_name = ReadOnly"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("expected diff to contain %q, got:\n%s", want, diff)
+		}
+	}
+	got, _ := os.ReadFile(configPath)
+	if string(got) != configureFixture {
+		t.Fatalf("dry run modified the config file:\n%s", got)
+	}
+}
+
+func TestConfigure_Errors(t *testing.T) {
+	setupConfigure(t)
+	cases := []struct {
+		name string
+		opts configureOptions
+		want string
+	}{
+		{"unknown session", configureOptions{session: "nope"}, "no [sso-session nope] section"},
+		{"bad template", configureOptions{session: "corp", nameTemplate: "{{.Nope}}"}, "invalid --name-template"},
+		{"duplicate names", configureOptions{session: "corp", nameTemplate: "{{.RoleName}}"}, `profile name "ReadOnly" is generated for both`},
+		{"empty name", configureOptions{session: "corp", nameTemplate: " "}, "invalid profile name"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := configure(tc.opts)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -2,39 +2,113 @@ package cli
 
 import (
 	"fmt"
+	"github.com/go-ini/ini"
 	"github.com/sirupsen/logrus"
 	"github.com/witnsby/aws-sso-login/src/internal/helper"
 	"net/url"
 	"time"
 )
 
+// partitionKey overrides the AWS partition per profile in ~/.aws/config.
+const partitionKey = "sso_login_partition"
+
+// consoleOptions tunes how console signs in and where it lands.
+type consoleOptions struct {
+	forceLogout bool
+	logoutWait  int
+	// destination is a console URL or path to open after sign-in.
+	destination string
+	// service is a shorthand destination such as "s3" or "cloudwatch".
+	service string
+	// region overrides the console region (default: profile region, then sso_region).
+	region string
+	// print writes the sign-in URL to stdout instead of opening a browser.
+	print bool
+	// copy places the sign-in URL on the clipboard instead of opening a browser.
+	copy bool
+	// browser is a command template used instead of the platform URL handler.
+	browser string
+	// isolation opens each profile in its own browser container or profile.
+	isolation string
+	// sessionDuration is the requested console session length, e.g. "2h".
+	sessionDuration string
+}
+
 // console generates a sign-in URL for an AWS SSO console session and opens it in a browser.
 // It optionally logs out of an existing session before opening the new one.
-func console(profile string, forceLogout bool, logoutWait int) error {
+// With --print or --copy the URL is handed to the user instead and no browser is started.
+// With browser isolation each profile gets its own container or browser
+// profile, so no logout is needed and several accounts can be open side by side.
+func console(profile string, opts consoleOptions) error {
 	// Retrieve profile details
 	manager := awsCredentialsManager{profileName: profile}
 	// Retrieve AWS profile and credentials
 	if err := manager.retrieveAndSetProfile(); err != nil {
 		return err
 	}
-	signinToken, err := getSigninToken(manager.roleCred)
+	sessionDuration, err := resolveSessionDuration(opts.sessionDuration, manager.profile, manager.roleCred.Expiration, time.Now())
+	if err != nil {
+		return err
+	}
+	delivered := opts.print || opts.copy
+	isolation, err := resolveBrowserIsolation(opts.isolation, manager.profile)
+	if err != nil {
+		return err
+	}
+	var open func(string) error
+	if !delivered {
+		open, err = isolatedOpener(isolation, resolveBrowserCommand(opts.browser, manager.profile), profile)
+		if err != nil {
+			return err
+		}
+	}
+	manager.partition, err = resolvePartition(manager.profile)
+	if err != nil {
+		return err
+	}
+	signinToken, err := getSigninToken(manager.roleCred, manager.partition, sessionDuration)
 	if err != nil {
 		return err
 	}
 	manager.signinToken = signinToken
 
-	manager.region = manager.profile.Key("sso_region").String()
-	manager.account = manager.profile.Key("sso_account_id").String()
+	manager.region = consoleRegion(manager.profile, opts.region)
+	manager.account, _ = profileAccountAndRole(manager.profile)
 	if err = manager.validateProfileParams(); err != nil {
 		logrus.Error(err)
 		return err
 	}
+	manager.destination, err = resolveConsoleDestination(opts.destination, opts.service, manager.region, manager.partition)
+	if err != nil {
+		return err
+	}
 	// Construct the sign-in URL
 	signinURL := manager.generateSigninURL()
-	// Handle optional logout
-	manager.handleLogout(forceLogout, logoutWait)
+	if delivered {
+		return deliverSigninURL(signinURL, opts.print, opts.copy)
+	}
+	// Handle optional logout; isolated sessions don't share a console session
+	if isolation == isolationNone {
+		if err = manager.handleLogout(open, opts.forceLogout, opts.logoutWait); err != nil {
+			return err
+		}
+	}
 	// Open the new session in a browser
-	openBrowser(signinURL)
+	return open(signinURL)
+}
+
+// deliverSigninURL prints the sign-in URL to stdout and/or copies it to the clipboard.
+func deliverSigninURL(signinURL string, toStdout, toClipboard bool) error {
+	if toClipboard {
+		if err := copyToClipboard(signinURL); err != nil {
+			return fmt.Errorf("failed to copy sign-in URL to clipboard: %w", err)
+		}
+		logrus.Info("Console sign-in URL copied to clipboard; it is valid for 15 minutes")
+	}
+	if toStdout {
+		// Printing the token is the point of --print, so it is not redacted.
+		printFunc("%s\n", signinURL)
+	}
 	return nil
 }
 
@@ -43,20 +117,45 @@ func (m *awsCredentialsManager) generateSigninURL() string {
 	params := url.Values{}
 	params.Set("Action", "login")
 	params.Set("Issuer", "-aws-sso-console")
-	params.Set("Destination", helper.ConsoleUrl(m.region))
+	params.Set("Destination", m.destination)
 	params.Set("SigninToken", m.signinToken)
-	return fmt.Sprintf("https://%s.signin.aws.amazon.com/federation?%s", m.account, params.Encode())
+	return m.partition.SigninURL(m.account) + "?" + params.Encode()
+}
+
+// resolvePartition picks the AWS partition for the profile from its
+// sso_login_partition key, else from its region or sso_region.
+func resolvePartition(profile *ini.Section) (helper.Partition, error) {
+	partition, err := helper.ResolvePartition(profile.Key(partitionKey).String(), consoleRegion(profile, ""))
+	if err != nil {
+		return helper.Partition{}, fmt.Errorf("invalid %s in profile: %w", partitionKey, err)
+	}
+	return partition, nil
+}
+
+// consoleRegion picks the console region: the --region flag, else the
+// profile's region, else its sso_region.
+func consoleRegion(profile *ini.Section, override string) string {
+	if override != "" {
+		return override
+	}
+	if region := profile.Key("region").String(); region != "" {
+		return region
+	}
+	return profile.Key("sso_region").String()
 }
 
 // handleLogout optionally logs out of the existing session.
-func (m *awsCredentialsManager) handleLogout(forceLogout bool, logoutWait int) {
+func (m *awsCredentialsManager) handleLogout(open func(string) error, forceLogout bool, logoutWait int) error {
 	if forceLogout || logoutWait > 0 {
-		logoutURL := helper.ConsoleLogout(m.region)
-		openBrowser(logoutURL)
+		logoutURL := m.partition.LogoutURL(m.region)
+		if err := open(logoutURL); err != nil {
+			return err
+		}
 		if logoutWait > 0 {
 			time.Sleep(time.Duration(logoutWait) * time.Second)
 		}
 	}
+	return nil
 }
 
 // validateProfileParams checks required profile parameters.
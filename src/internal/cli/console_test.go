@@ -1 +1,113 @@
 package cli
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/go-ini/ini"
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+)
+
+func TestConsoleRegion(t *testing.T) {
+	cfg, err := ini.Load([]byte(`[profile both]
+region = eu-west-1
+sso_region = us-east-1
+
+[profile sso-only]
+sso_region = us-east-1
+`))
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+
+	if got := consoleRegion(cfg.Section("profile both"), ""); got != "eu-west-1" {
+		t.Fatalf("expected profile region, got %q", got)
+	}
+	if got := consoleRegion(cfg.Section("profile sso-only"), ""); got != "us-east-1" {
+		t.Fatalf("expected sso_region fallback, got %q", got)
+	}
+	if got := consoleRegion(cfg.Section("profile both"), "ap-south-1"); got != "ap-south-1" {
+		t.Fatalf("expected --region override, got %q", got)
+	}
+}
+
+func TestGenerateSigninURL(t *testing.T) {
+	m := awsCredentialsManager{
+		account:     "123456789012",
+		partition:   helper.PartitionAWS,
+		signinToken: "token",
+		destination: "https://eu-west-1.console.aws.amazon.com/s3/home?region=eu-west-1",
+	}
+
+	u, err := url.Parse(m.generateSigninURL())
+	if err != nil {
+		t.Fatalf("parse sign-in URL: %v", err)
+	}
+	if u.Host != "123456789012.signin.aws.amazon.com" {
+		t.Fatalf("unexpected host %q", u.Host)
+	}
+	q := u.Query()
+	if q.Get("Action") != "login" || q.Get("SigninToken") != "token" || q.Get("Destination") != m.destination {
+		t.Fatalf("unexpected query %v", q)
+	}
+}
+
+func TestGenerateSigninURL_GovCloud(t *testing.T) {
+	m := awsCredentialsManager{
+		account:     "123456789012",
+		partition:   helper.PartitionAWSUSGov,
+		signinToken: "token",
+		destination: "https://console.amazonaws-us-gov.com/console/home?region=us-gov-west-1",
+	}
+
+	u, err := url.Parse(m.generateSigninURL())
+	if err != nil {
+		t.Fatalf("parse sign-in URL: %v", err)
+	}
+	if u.Host != "signin.amazonaws-us-gov.com" || u.Path != "/federation" {
+		t.Fatalf("unexpected sign-in URL %q", u)
+	}
+}
+
+func TestResolvePartition(t *testing.T) {
+	cfg, err := ini.Load([]byte(`[profile commercial]
+sso_region = us-east-1
+
+[profile gov]
+sso_region = us-gov-west-1
+
+[profile china]
+region = cn-northwest-1
+sso_region = cn-north-1
+
+[profile pinned]
+sso_region = us-east-1
+sso_login_partition = aws-us-gov
+
+[profile bogus]
+sso_region = us-east-1
+sso_login_partition = aws-iso
+`))
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+
+	for section, want := range map[string]string{
+		"profile commercial": "aws",
+		"profile gov":        "aws-us-gov",
+		"profile china":      "aws-cn",
+		"profile pinned":     "aws-us-gov",
+	} {
+		got, err := resolvePartition(cfg.Section(section))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", section, err)
+		}
+		if got.ID != want {
+			t.Fatalf("%s: expected partition %q, got %q", section, want, got.ID)
+		}
+	}
+
+	if _, err := resolvePartition(cfg.Section("profile bogus")); err == nil {
+		t.Fatal("expected an error for an unknown partition")
+	}
+}
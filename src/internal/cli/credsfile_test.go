@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestUpdateCredsFile_Golden imports credentials for [dev] (and prunes, for
+// the prune case) into each testdata/credentials/*.input file and compares the
+// result with the matching .golden file. Everything outside the keys import
+// writes must come back byte for byte.
+func TestUpdateCredsFile_Golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "credentials", "*.input"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no golden inputs: %v", err)
+	}
+	origNow := importNow
+	t.Cleanup(func() { importNow = origNow })
+	importNow = func() time.Time { return time.Date(2026, 5, 6, 12, 0, 0, 0, time.UTC) }
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".input")
+		t.Run(name, func(t *testing.T) {
+			dir := isolateAwsPaths(t)
+			credsPath := filepath.Join(dir, "credentials")
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatalf("read input: %v", err)
+			}
+			if err := os.WriteFile(credsPath, data, 0o600); err != nil {
+				t.Fatalf("write credentials: %v", err)
+			}
+
+			manager := awsCredentialsManager{pruneExpired: name == "prune"}
+			err = manager.updateCredsFile(map[string]*model.RoleCredential{"dev": {
+				AccessKeyId:     "ASIANEW",
+				SecretAccessKey: "new-secret",
+				SessionToken:    "new-token",
+				Expiration:      "2099-01-01T00:00:00Z",
+			}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := os.ReadFile(credsPath)
+			if err != nil {
+				t.Fatalf("read credentials: %v", err)
+			}
+
+			golden := strings.TrimSuffix(input, ".input") + ".golden"
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatalf("write golden: %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden (run with -update to create it): %v", err)
+			}
+			if string(got) != string(want) {
+				t.Fatalf("credentials file mismatch\n--- got ---\n%q\n--- want ---\n%q", got, want)
+			}
+		})
+	}
+}
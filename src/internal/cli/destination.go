@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+)
+
+// serviceShortcuts maps --service names to console paths where the console
+// does not follow the generic "/<service>/home" layout. The placeholder
+// {region} is replaced with the console region.
+var serviceShortcuts = map[string]string{
+	"billing":        "/billing/home",
+	"cloudformation": "/cloudformation/home?region={region}",
+	"cloudwatch":     "/cloudwatch/home?region={region}",
+	"dynamodb":       "/dynamodbv2/home?region={region}",
+	"ec2":            "/ec2/home?region={region}",
+	"ecr":            "/ecr/repositories?region={region}",
+	"ecs":            "/ecs/v2/clusters?region={region}",
+	"eks":            "/eks/home?region={region}",
+	"iam":            "/iam/home",
+	"lambda":         "/lambda/home?region={region}",
+	"logs":           "/cloudwatch/home?region={region}#logsV2:log-groups",
+	"rds":            "/rds/home?region={region}",
+	"route53":        "/route53/v2/home",
+	"s3":             "/s3/home?region={region}",
+	"secretsmanager": "/secretsmanager/listsecrets?region={region}",
+	"sns":            "/sns/v3/home?region={region}",
+	"sqs":            "/sqs/v3/home?region={region}",
+	"ssm":            "/systems-manager/home?region={region}",
+	"vpc":            "/vpcconsole/home?region={region}",
+}
+
+// serviceNamePattern restricts --service to plain console path segments.
+var serviceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// resolveConsoleDestination returns the console URL to land on after sign-in.
+//
+// destination may be an absolute URL on the console domain or a path such as
+// "/ec2/home", which is resolved against the partition's console. service is
+// a shorthand such as "s3" or "cloudwatch". With neither set, the console
+// home page for region is returned.
+func resolveConsoleDestination(destination, service, region string, partition helper.Partition) (string, error) {
+	base := partition.ConsoleBaseURL(region)
+	switch {
+	case destination != "" && service != "":
+		return "", fmt.Errorf("--destination and --service cannot be used together")
+	case service != "":
+		return serviceDestination(base, service, region)
+	case destination != "":
+		return validateDestination(base, destination, partition)
+	default:
+		return partition.ConsoleURL(region), nil
+	}
+}
+
+// serviceDestination builds the console URL for a --service shorthand.
+func serviceDestination(base, service, region string) (string, error) {
+	service = strings.ToLower(strings.TrimSpace(service))
+	if !serviceNamePattern.MatchString(service) {
+		return "", fmt.Errorf("invalid --service %q", service)
+	}
+	path, ok := serviceShortcuts[service]
+	if !ok {
+		path = "/" + service + "/home?region={region}"
+	}
+	path = strings.ReplaceAll(path, "{region}", url.QueryEscape(region))
+	return strings.TrimSuffix(base, "/") + path, nil
+}
+
+// validateDestination resolves a --destination path against base and makes
+// sure absolute URLs stay on the partition's console domain over HTTPS.
+func validateDestination(base, destination string, partition helper.Partition) (string, error) {
+	if strings.HasPrefix(destination, "/") && !strings.HasPrefix(destination, "//") {
+		return strings.TrimSuffix(base, "/") + destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("invalid --destination %q: %w", destination, err)
+	}
+	if u.Scheme != "https" || !partition.IsConsoleHost(u.Hostname()) || u.User != nil {
+		return "", fmt.Errorf("--destination %q must be an https URL on %s or a path such as /ec2/home",
+			destination, partition.ConsoleDomain)
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+)
+
+func TestResolveConsoleDestination(t *testing.T) {
+	cases := []struct {
+		name        string
+		destination string
+		service     string
+		region      string
+		partition   *helper.Partition
+		want        string
+		wantErr     string
+	}{
+		{
+			name:   "default is the regional console home",
+			region: "us-east-1",
+			want:   "https://us-east-1.console.aws.amazon.com/",
+		},
+		{
+			name:    "service shortcut",
+			service: "s3",
+			region:  "eu-west-1",
+			want:    "https://eu-west-1.console.aws.amazon.com/s3/home?region=eu-west-1",
+		},
+		{
+			name:    "service with fragment",
+			service: "logs",
+			region:  "eu-west-1",
+			want:    "https://eu-west-1.console.aws.amazon.com/cloudwatch/home?region=eu-west-1#logsV2:log-groups",
+		},
+		{
+			name:    "unknown service uses generic layout",
+			service: "Kinesis",
+			region:  "us-west-2",
+			want:    "https://us-west-2.console.aws.amazon.com/kinesis/home?region=us-west-2",
+		},
+		{
+			name:    "service must be a plain name",
+			service: "../evil",
+			region:  "us-east-1",
+			wantErr: "invalid --service",
+		},
+		{
+			name:        "path destination",
+			destination: "/ec2/home?region=us-east-1#Instances:",
+			region:      "us-east-1",
+			want:        "https://us-east-1.console.aws.amazon.com/ec2/home?region=us-east-1#Instances:",
+		},
+		{
+			name:        "absolute console URL",
+			destination: "https://console.aws.amazon.com/iam/home",
+			region:      "us-east-1",
+			want:        "https://console.aws.amazon.com/iam/home",
+		},
+		{
+			name:        "off-domain URL rejected",
+			destination: "https://console.aws.amazon.com.evil.example/",
+			region:      "us-east-1",
+			wantErr:     "must be an https URL on console.aws.amazon.com",
+		},
+		{
+			name:        "protocol-relative URL rejected",
+			destination: "//evil.example/",
+			region:      "us-east-1",
+			wantErr:     "must be an https URL",
+		},
+		{
+			name:        "plain http rejected",
+			destination: "http://us-east-1.console.aws.amazon.com/",
+			region:      "us-east-1",
+			wantErr:     "must be an https URL",
+		},
+		{
+			name:      "govcloud console home",
+			region:    "us-gov-west-1",
+			partition: &helper.PartitionAWSUSGov,
+			want:      "https://console.amazonaws-us-gov.com/console/home?region=us-gov-west-1",
+		},
+		{
+			name:      "china service shortcut",
+			service:   "ec2",
+			region:    "cn-north-1",
+			partition: &helper.PartitionAWSCN,
+			want:      "https://console.amazonaws.cn/ec2/home?region=cn-north-1",
+		},
+		{
+			name:        "commercial URL rejected in govcloud",
+			destination: "https://console.aws.amazon.com/iam/home",
+			region:      "us-gov-west-1",
+			partition:   &helper.PartitionAWSUSGov,
+			wantErr:     "must be an https URL on console.amazonaws-us-gov.com",
+		},
+		{
+			name:        "destination and service are exclusive",
+			destination: "/ec2/home",
+			service:     "s3",
+			wantErr:     "cannot be used together",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			partition := helper.PartitionAWS
+			if tc.partition != nil {
+				partition = *tc.partition
+			}
+			got, err := resolveConsoleDestination(tc.destination, tc.service, tc.region, partition)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v (%q)", tc.wantErr, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("resolveConsoleDestination() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// envVar is a single environment variable to export.
+type envVar struct {
+	name  string
+	value string
+}
+
+// exportFormats maps the names accepted by export --format to formatters.
+var exportFormats = map[string]func([]envVar) (string, error){
+	"sh":         formatSh,
+	"fish":       formatFish,
+	"powershell": formatPowerShell,
+	"cmd":        formatCmd,
+	"dotenv":     formatDotenv,
+	"json":       formatJSON,
+}
+
+// dotenvSafeValue matches values that need no quoting in a .env file.
+var dotenvSafeValue = regexp.MustCompile(`^[A-Za-z0-9_./:+=@,-]*$`)
+
+// exportFormatNames lists the valid export formats for error messages.
+func exportFormatNames() string {
+	names := make([]string, 0, len(exportFormats))
+	for name := range exportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// detectExportFormat picks the export format for the user's shell from
+// $SHELL: fish, PowerShell (pwsh) or POSIX sh. On Windows without $SHELL it
+// chooses PowerShell when PSModulePath is set and cmd otherwise.
+func detectExportFormat() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		name := strings.TrimSuffix(strings.ToLower(filepath.Base(shell)), ".exe")
+		switch name {
+		case "fish":
+			return "fish"
+		case "pwsh", "powershell":
+			return "powershell"
+		}
+		return "sh"
+	}
+	if runtime.GOOS == "windows" {
+		if os.Getenv("PSModulePath") != "" {
+			return "powershell"
+		}
+		return "cmd"
+	}
+	return "sh"
+}
+
+// formatSh emits POSIX `export NAME='value'` lines. Single quotes suppress all
+// expansion; an embedded single quote closes the string, is written as \'
+// and the string is reopened.
+func formatSh(vars []envVar) (string, error) {
+	var b strings.Builder
+	for _, v := range vars {
+		fmt.Fprintf(&b, "export %s='%s'\n", v.name, strings.ReplaceAll(v.value, `'`, `'\''`))
+	}
+	return b.String(), nil
+}
+
+// formatFish emits `set -gx NAME 'value'` lines. Inside fish single quotes
+// only backslash and single quote need escaping.
+func formatFish(vars []envVar) (string, error) {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	var b strings.Builder
+	for _, v := range vars {
+		fmt.Fprintf(&b, "set -gx %s '%s'\n", v.name, r.Replace(v.value))
+	}
+	return b.String(), nil
+}
+
+// formatPowerShell emits `$Env:NAME = 'value'` lines. PowerShell verbatim
+// strings only need single quotes doubled.
+func formatPowerShell(vars []envVar) (string, error) {
+	var b strings.Builder
+	for _, v := range vars {
+		fmt.Fprintf(&b, "$Env:%s = '%s'\n", v.name, strings.ReplaceAll(v.value, `'`, `''`))
+	}
+	return b.String(), nil
+}
+
+// formatCmd emits `set "NAME=value"` lines for cmd.exe. cmd has no reliable
+// escaping for quotes, percent signs, exclamation marks or line breaks, so
+// values containing them are rejected rather than exported incorrectly.
+func formatCmd(vars []envVar) (string, error) {
+	var b strings.Builder
+	for _, v := range vars {
+		if strings.ContainsAny(v.value, "\"%!\r\n") {
+			return "", fmt.Errorf("value of %s cannot be represented safely in cmd format", v.name)
+		}
+		fmt.Fprintf(&b, "set \"%s=%s\"\n", v.name, v.value)
+	}
+	return b.String(), nil
+}
+
+// formatDotenv emits NAME=value lines. Values with characters outside a
+// conservative safe set are double-quoted with \, ", $ and line breaks escaped.
+func formatDotenv(vars []envVar) (string, error) {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`)
+	var b strings.Builder
+	for _, v := range vars {
+		if dotenvSafeValue.MatchString(v.value) {
+			fmt.Fprintf(&b, "%s=%s\n", v.name, v.value)
+			continue
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"\n", v.name, r.Replace(v.value))
+	}
+	return b.String(), nil
+}
+
+// formatJSON emits a single JSON object mapping names to values.
+func formatJSON(vars []envVar) (string, error) {
+	m := make(map[string]string, len(vars))
+	for _, v := range vars {
+		m[v.name] = v.value
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal variables: %w", err)
+	}
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+)
+
+// trickyValue exercises every quoting rule the formatters care about.
+const trickyValue = `a'b"c$HOME` + "`id`" + `\d!e%f`
+
+func TestExportFormats(t *testing.T) {
+	vars := []envVar{{"AWS_SESSION_TOKEN", trickyValue}}
+	cases := map[string]string{
+		"sh":         `export AWS_SESSION_TOKEN='a'\''b"c$HOME` + "`id`" + `\d!e%f'` + "\n",
+		"fish":       `set -gx AWS_SESSION_TOKEN 'a\'b"c$HOME` + "`id`" + `\\d!e%f'` + "\n",
+		"powershell": `$Env:AWS_SESSION_TOKEN = 'a''b"c$HOME` + "`id`" + `\d!e%f'` + "\n",
+		"dotenv":     `AWS_SESSION_TOKEN="a'b\"c\$HOME` + "`id`" + `\\d!e%f"` + "\n",
+	}
+	for format, want := range cases {
+		got, err := exportFormats[format](vars)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+		if got != want {
+			t.Errorf("%s:\n got %s\nwant %s", format, got, want)
+		}
+	}
+
+	got, err := formatCmd([]envVar{{"AWS_DEFAULT_REGION", "eu-west-1"}})
+	if err != nil || got != "set \"AWS_DEFAULT_REGION=eu-west-1\"\n" {
+		t.Errorf("cmd: got %q, %v", got, err)
+	}
+	if _, err := formatCmd(vars); err == nil {
+		t.Error("cmd: expected an error for a value that cannot be quoted")
+	}
+
+	got, err = formatDotenv([]envVar{{"AWS_SECRET_ACCESS_KEY", "abc/DEF+ghi="}})
+	if err != nil || got != "AWS_SECRET_ACCESS_KEY=abc/DEF+ghi=\n" {
+		t.Errorf("dotenv: expected safe values unquoted, got %q, %v", got, err)
+	}
+
+	got, err = formatJSON(vars)
+	if err != nil {
+		t.Fatalf("json: unexpected error: %v", err)
+	}
+	var decoded map[string]string
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil || decoded["AWS_SESSION_TOKEN"] != trickyValue {
+		t.Errorf("json: round trip failed: %q, %v", got, err)
+	}
+}
+
+// TestExportFormats_ShellRoundTrip evaluates the sh output in a real shell and
+// checks the value survives unchanged.
+func TestExportFormats_ShellRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	script, _ := formatSh([]envVar{{"AWS_SESSION_TOKEN", trickyValue}})
+	out, err := exec.Command(sh, "-c", script+`printf %s "$AWS_SESSION_TOKEN"`).Output()
+	if err != nil {
+		t.Fatalf("run sh: %v", err)
+	}
+	if string(out) != trickyValue {
+		t.Fatalf("expected %q after eval, got %q", trickyValue, out)
+	}
+}
+
+func TestDetectExportFormat(t *testing.T) {
+	cases := map[string]string{
+		"/usr/bin/fish":       "fish",
+		"/usr/local/bin/pwsh": "powershell",
+		"/bin/bash":           "sh",
+		"/bin/zsh":            "sh",
+	}
+	for shell, want := range cases {
+		t.Setenv("SHELL", shell)
+		if got := detectExportFormat(); got != want {
+			t.Errorf("SHELL=%s: expected %q, got %q", shell, want, got)
+		}
+	}
+}
+
+func TestExportCredsToOutput_Format(t *testing.T) {
+	writeAwsConfig(t, `[profile dev-account]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+region = eu-west-1
+`)
+	isolateAwsPaths(t)
+	useStaticSource(t, StaticSource{Credential: &model.RoleCredential{
+		AccessKeyId:     "ASIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		Expiration:      "2026-01-01T00:00:00Z",
+	}})
+
+	var buf bytes.Buffer
+	orig := exportOutput
+	t.Cleanup(func() { exportOutput = orig })
+	exportOutput = &buf
+
+	if err := exportCredsToOutput("dev-account", "fish"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := strings.Join([]string{
+		"set -gx AWS_ACCESS_KEY_ID 'ASIAEXAMPLE'",
+		"set -gx AWS_SECRET_ACCESS_KEY 'secret'",
+		"set -gx AWS_SESSION_TOKEN 'token'",
+		"set -gx AWS_SECURITY_TOKEN 'token'",
+		"set -gx AWS_CREDENTIAL_EXPIRATION '2026-01-01T00:00:00Z'",
+		"set -gx AWS_DEFAULT_REGION 'eu-west-1'",
+	}, "\n") + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+
+	if err := exportCredsToOutput("dev-account", "tcsh"); err == nil || !strings.Contains(err.Error(), "unknown export format") {
+		t.Fatalf("expected unknown format error, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// execStrippedEnv lists variables removed from the child environment because
+// they would override or conflict with the injected credentials.
+var execStrippedEnv = []string{
+	"AWS_PROFILE",
+	"AWS_DEFAULT_PROFILE",
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_SECURITY_TOKEN",
+	"AWS_CREDENTIAL_EXPIRATION",
+	"AWS_ROLE_ARN",
+	"AWS_ROLE_SESSION_NAME",
+	"AWS_WEB_IDENTITY_TOKEN_FILE",
+	"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+	"AWS_CONTAINER_CREDENTIALS_FULL_URI",
+	"AWS_CONTAINER_AUTHORIZATION_TOKEN",
+	"AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE",
+}
+
+// forwardedSignals are relayed from this process to the child.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// childExitError carries the exit status of a child started by exec so that
+// Run can exit with the same code without printing anything.
+type childExitError struct {
+	code int
+}
+
+func (e *childExitError) Error() string {
+	return fmt.Sprintf("command exited with status %d", e.code)
+}
+
+// execWithCreds runs command with the profile's role credentials injected
+// into its environment. The credentials never touch this process's
+// environment, so they don't leak into the parent shell. Signals received
+// while the child runs are forwarded to it, and a non-zero child exit status
+// is returned as a *childExitError.
+func execWithCreds(profileName string, command []string) error {
+	if len(command) == 0 {
+		return errors.New("no command given; usage: exec [--profile name] -- command [args...]")
+	}
+
+	manager := awsCredentialsManager{profileName: profileName}
+	if err := manager.retrieveAndSetProfile(); err != nil {
+		return err
+	}
+
+	region := manager.profile.Key("region").String()
+	vars := []envVar{
+		{"AWS_ACCESS_KEY_ID", manager.roleCred.AccessKeyId},
+		{"AWS_SECRET_ACCESS_KEY", manager.roleCred.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", manager.roleCred.SessionToken},
+		{"AWS_CREDENTIAL_EXPIRATION", credentialExpiration(manager.roleCred.Expiration)},
+	}
+	if region != "" {
+		vars = append(vars, envVar{"AWS_REGION", region}, envVar{"AWS_DEFAULT_REGION", region})
+	}
+
+	cmd := execCommand(command[0], command[1:]...)
+	cmd.Env = childEnv(os.Environ(), vars)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return runForwardingSignals(cmd)
+}
+
+// childEnv returns environ without the conflicting AWS variables (and any
+// variable being set by vars), followed by vars.
+func childEnv(environ []string, vars []envVar) []string {
+	drop := make(map[string]bool, len(execStrippedEnv)+len(vars))
+	for _, name := range execStrippedEnv {
+		drop[name] = true
+	}
+	for _, v := range vars {
+		drop[v.name] = true
+	}
+
+	env := make([]string, 0, len(environ)+len(vars))
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		// Environment names are case-insensitive on Windows.
+		if drop[strings.ToUpper(name)] {
+			continue
+		}
+		env = append(env, kv)
+	}
+	for _, v := range vars {
+		if v.value != "" {
+			env = append(env, v.name+"="+v.value)
+		}
+	}
+	return env
+}
+
+// runForwardingSignals starts cmd, relays forwardedSignals to it until it
+// exits, and maps a non-zero exit status onto a *childExitError. A child
+// killed by a signal reports 128+signal, as shells do.
+func runForwardingSignals(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardedSignals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	signal.Stop(sigs)
+	close(done)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			code = 128 + int(status.Signal())
+		}
+		return &childExitError{code: code}
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+)
+
+func TestChildEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"AWS_PROFILE=other",
+		"AWS_ACCESS_KEY_ID=AKIAPARENT",
+		"aws_session_token=stale",
+		"AWS_REGION=us-east-1",
+		"AWS_CONFIG_FILE=/tmp/config",
+	}
+	vars := []envVar{
+		{"AWS_ACCESS_KEY_ID", "ASIAEXAMPLE"},
+		{"AWS_SESSION_TOKEN", "token"},
+		{"AWS_REGION", "eu-west-1"},
+		{"AWS_CREDENTIAL_EXPIRATION", ""},
+	}
+
+	got := strings.Join(childEnv(environ, vars), "\n")
+	want := strings.Join([]string{
+		"PATH=/usr/bin",
+		"AWS_CONFIG_FILE=/tmp/config",
+		"AWS_ACCESS_KEY_ID=ASIAEXAMPLE",
+		"AWS_SESSION_TOKEN=token",
+		"AWS_REGION=eu-west-1",
+	}, "\n")
+	if got != want {
+		t.Fatalf("unexpected child env:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestExecWithCreds(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	writeAwsConfig(t, `[profile dev-account]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+region = eu-west-1
+`)
+	dir := isolateAwsPaths(t)
+	useStaticSource(t, StaticSource{Credential: &model.RoleCredential{
+		AccessKeyId:     "ASIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		Expiration:      "2026-01-01T00:00:00Z",
+	}})
+	t.Setenv("AWS_PROFILE", "parent-profile")
+
+	out := filepath.Join(dir, "env")
+	err := execWithCreds("dev-account", []string{"sh", "-c",
+		`printf '%s|%s|%s|%s|%s' "$AWS_ACCESS_KEY_ID" "$AWS_SESSION_TOKEN" "$AWS_REGION" "$AWS_CREDENTIAL_EXPIRATION" "${AWS_PROFILE-unset}" > "$0"; exit 3`,
+		out})
+
+	var exitErr *childExitError
+	if !errors.As(err, &exitErr) || exitErr.code != 3 {
+		t.Fatalf("expected child exit status 3, got %v", err)
+	}
+	data, readErr := os.ReadFile(out)
+	if readErr != nil {
+		t.Fatalf("read child output: %v", readErr)
+	}
+	if string(data) != "ASIAEXAMPLE|token|eu-west-1|2026-01-01T00:00:00Z|unset" {
+		t.Fatalf("unexpected child environment %q", data)
+	}
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "ASIAEXAMPLE" {
+		t.Fatal("credentials leaked into the parent environment")
+	}
+
+	if err := execWithCreds("dev-account", []string{"true"}); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+}
+
+func TestRunForwardingSignals_SignalledChild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX signals")
+	}
+	err := runForwardingSignals(exec.Command("sh", "-c", "kill -TERM $$"))
+	var exitErr *childExitError
+	if !errors.As(err, &exitErr) || exitErr.code != 143 {
+		t.Fatalf("expected exit status 143, got %v", err)
+	}
+}
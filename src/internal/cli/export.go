@@ -2,13 +2,20 @@ package cli
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"time"
 )
 
+// exportOutput receives the exported variables.
+var exportOutput io.Writer = os.Stdout
+
 // exportCredsToOutput retrieves AWS credentials and region for a given profile
 // and prints them in a shell-exportable format.
 //
 // Parameters:
 // - profileName: The name of the AWS profile to export.
+// - format: One of the exportFormats, or "" to detect the shell from $SHELL.
 //
 // Behavior:
 // 1. Retrieves the AWS profile and associated region.
@@ -19,36 +26,62 @@ import (
 //   - AWS_SECRET_ACCESS_KEY
 //   - AWS_SESSION_TOKEN
 //   - AWS_SECURITY_TOKEN
+//   - AWS_CREDENTIAL_EXPIRATION (RFC 3339, as read by the AWS SDKs)
 //   - AWS_DEFAULT_REGION (if a region is set).
 //
 // Returns:
-// - An error if profile retrieval or credential generation fails.
-func exportCredsToOutput(profileName string) error {
+// - An error if profile retrieval, credential generation or formatting fails.
+func exportCredsToOutput(profileName, format string) error {
+	if format == "" {
+		format = detectExportFormat()
+	}
+	formatter, ok := exportFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown export format %q (valid: %s)", format, exportFormatNames())
+	}
+
 	profile, err := retrieveProfile(profileName)
 	if err != nil {
 		return err
 	}
 
 	awsRegion := profile.Key("region").String()
-	roleCred, err := getRoleCredentials(profileName, profile, true)
+	roleCred, err := getRoleCredentials(profileName, profile)
 	if err != nil {
 		return err
 	}
 
-	// Print in a shell-exportable format
-	printEnvVariable("AWS_ACCESS_KEY_ID", roleCred.AccessKeyId)
-	printEnvVariable("AWS_SECRET_ACCESS_KEY", roleCred.SecretAccessKey)
-	printEnvVariable("AWS_SESSION_TOKEN", roleCred.SessionToken)
-	printEnvVariable("AWS_SECURITY_TOKEN", roleCred.SessionToken)
+	vars := []envVar{
+		{"AWS_ACCESS_KEY_ID", roleCred.AccessKeyId},
+		{"AWS_SECRET_ACCESS_KEY", roleCred.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", roleCred.SessionToken},
+		{"AWS_SECURITY_TOKEN", roleCred.SessionToken},
+		{"AWS_CREDENTIAL_EXPIRATION", credentialExpiration(roleCred.Expiration)},
+		{"AWS_DEFAULT_REGION", awsRegion},
+	}
 
-	if awsRegion != "" {
-		printEnvVariable("AWS_DEFAULT_REGION", awsRegion)
+	// Print in the requested format, skipping unset values
+	set := vars[:0]
+	for _, v := range vars {
+		if v.value != "" {
+			set = append(set, v)
+		}
 	}
-	return nil
+	out, err := formatter(set)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(exportOutput, out)
+	return err
 }
 
-func printEnvVariable(name, value string) {
-	if value != "" {
-		fmt.Printf("export %s=%q\n", name, value)
+// credentialExpiration normalises a cached expiration timestamp to the UTC
+// RFC 3339 form the SDKs expect in AWS_CREDENTIAL_EXPIRATION. Unparseable
+// values are passed through unchanged.
+func credentialExpiration(expiration string) string {
+	t, err := parseExpirationTime(expiration)
+	if err != nil {
+		return expiration
 	}
+	return t.UTC().Format(time.RFC3339)
 }
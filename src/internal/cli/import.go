@@ -3,110 +3,252 @@ package cli
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-ini/ini"
 	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/fsutil"
 	"github.com/witnsby/aws-sso-login/src/internal/helper"
-	"github.com/witnsby/aws-sso-login/src/internal/profiles"
+	"github.com/witnsby/aws-sso-login/src/internal/inifile"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
 )
 
-// defaultSelector is the production Selector used when --profile is omitted.
-// It is a package-level seam so tests can swap in a deterministic fake.
-var defaultSelector Selector = HuhSelector{}
-
-// resolveProfileName returns the AWS profile name to import credentials for.
-//
-// If flagValue is non-empty, it is returned verbatim (the explicit --profile
-// flag bypasses the interactive selector). Otherwise, the AWS config file is
-// parsed for SSO-enabled profiles and the user is prompted via defaultSelector.
-func resolveProfileName(flagValue string) (string, error) {
-	if flagValue != "" {
-		return flagValue, nil
-	}
-
-	configPath, err := GetAwsConfigPath()
-	if err != nil {
-		return "", fmt.Errorf("could not determine AWS config path: %w", err)
-	}
-
-	ssoProfiles, err := profiles.ListSSOProfiles(configPath)
-	if err != nil {
-		return "", fmt.Errorf("could not list SSO profiles: %w", err)
-	}
+// Keys import writes next to the credentials, so later runs and other tools
+// can tell when a section goes stale and which sections this tool wrote.
+const (
+	// credsExpirationKey and credsTokenExpiresKey both hold the RFC 3339
+	// expiration; different tools read one or the other.
+	credsExpirationKey   = "aws_expiration"
+	credsTokenExpiresKey = "x_security_token_expires"
+	// credsSourceProfileKey names the config profile the section was imported
+	// from and marks it as written by this tool.
+	credsSourceProfileKey = "sso_login_source_profile"
+	// credsImportedAtKey holds the RFC 3339 time of the import.
+	credsImportedAtKey = "sso_login_imported_at"
+)
 
-	name, err := defaultSelector.Pick(ssoProfiles)
-	if err != nil {
-		return "", fmt.Errorf("could not select profile: %w", err)
-	}
-	return name, nil
+// importNow returns the time recorded in sso_login_imported_at.
+var importNow = time.Now
+
+// importOptions tunes how importCreds writes the credentials file.
+type importOptions struct {
+	// backup copies the existing credentials file to a timestamped .bak file
+	// before it is rewritten.
+	backup bool
+	// concurrency bounds the number of parallel credential fetches in a batch import.
+	concurrency int
+	// force refreshes sections whose imported credentials are still valid.
+	force bool
+	// prune removes expired sections previously written by import.
+	prune bool
 }
 
 // importCreds retrieves AWS credentials for a profile,
 // writes them in the credentials file, and saves the updated file.
-func importCreds(profileName string) error {
+// A section imported earlier that is still valid (outside the refresh window)
+// is left alone unless opts.force is set.
+func importCreds(profileName string, opts importOptions) error {
 	// Initialize the credentials manager
-	manager := awsCredentialsManager{profileName: profileName}
+	manager := awsCredentialsManager{profileName: profileName, backupCreds: opts.backup, pruneExpired: opts.prune}
+
+	if !opts.force {
+		profile, err := retrieveProfile(profileName)
+		if err != nil {
+			return err
+		}
+		if expiration, ok := importedCredsValid(profileName, profile); ok {
+			logrus.Infof("Credentials in profile [%s] are valid until %s; use --force to refresh them", profileName, expiration)
+			if opts.prune {
+				return manager.updateCredsFile(nil)
+			}
+			return nil
+		}
+	}
 
 	// Retrieve AWS profile and credentials
 	if err := manager.retrieveAndSetProfile(); err != nil {
 		return err
 	}
 
-	// Load or initialize the credentials file
-	if err := manager.loadOrInitCredsFile(); err != nil {
+	// Load, update and save the credentials file while holding its lock
+	if err := manager.updateCredsFile(map[string]*model.RoleCredential{profileName: manager.roleCred}); err != nil {
 		return err
 	}
 
-	// Update the credentials in the profile section
-	if err := manager.updateProfileWithCreds(); err != nil {
-		return err
-	}
+	logrus.Infof("Wrote credentials to profile [%s] in %s\n", manager.profileName, manager.credentialsPath)
+	return nil
+}
 
-	// Save the credentials file
-	if err := manager.saveCredsFile(); err != nil {
-		return err
+// pruneCreds removes expired sections previously written by import from the
+// credentials file without importing anything.
+func pruneCreds(opts importOptions) error {
+	manager := awsCredentialsManager{backupCreds: opts.backup, pruneExpired: true}
+	return manager.updateCredsFile(nil)
+}
+
+// importedCredsValid reports whether the credentials file already holds
+// credentials for profileName, written by import, that outlive the profile's
+// refresh window. It returns their expiration.
+func importedCredsValid(profileName string, profile *ini.Section) (string, bool) {
+	window, err := resolveRefreshWindow(profile)
+	if err != nil {
+		return "", false
 	}
+	path, err := helper.GetAwsCredentialsPath()
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	credsFile := inifile.Parse(data)
+	// A section import did not write for this profile (hand-edited, or
+	// written by another tool) is never trusted, whatever its expiration.
+	if source, ok := credsFile.Get(profileName, credsSourceProfileKey); !ok || source != profileName {
+		return "", false
+	}
+	expiration := sectionExpiration(credsFile, profileName)
+	if expiration == "" || expiresWithin(expiration, window) {
+		return "", false
+	}
+	return expiration, true
+}
 
-	logrus.Infof("Wrote credentials to profile [%s] in %s\n", manager.profileName, manager.credentialsPath)
-	return nil
+// sectionExpiration returns the expiration import recorded in a credentials
+// file section, or "" if there is none.
+func sectionExpiration(credsFile *inifile.File, section string) string {
+	if expiration, _ := credsFile.Get(section, credsExpirationKey); expiration != "" {
+		return expiration
+	}
+	expiration, _ := credsFile.Get(section, credsTokenExpiresKey)
+	return expiration
 }
 
-// loadOrInitCredsFile loads the credentials file or initializes an empty one if it doesn't exist.
-func (m *awsCredentialsManager) loadOrInitCredsFile() error {
+// updateCredsFile runs the load-modify-save cycle on the credentials file
+// under an exclusive advisory lock, so concurrent imports cannot interleave
+// and lose each other's sections. creds maps section names to the role
+// credentials written into them. With pruneExpired set, expired sections
+// previously written by import are removed in the same update; the file is
+// only rewritten when something changed.
+func (m *awsCredentialsManager) updateCredsFile(creds map[string]*model.RoleCredential) error {
 	path, err := helper.GetAwsCredentialsPath()
 	if err != nil {
 		return err
 	}
 	m.credentialsPath = path
 
-	credsFile, err := ini.Load(path)
+	unlock, err := fsutil.Lock(path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			m.credsFile = ini.Empty()
-			return nil
+		return fmt.Errorf("could not lock credentials file: %w", err)
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			logrus.Warnf("Failed to release lock on %s: %v", path, err)
 		}
+	}()
+
+	// Load or initialize the credentials file
+	if err := m.loadOrInitCredsFile(); err != nil {
 		return err
 	}
-	m.credsFile = credsFile
-	return nil
+
+	// Update the credentials in each profile section, in a stable order
+	names := make([]string, 0, len(creds))
+	for name := range creds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	now := importNow()
+	for _, name := range names {
+		if err := m.updateProfileWithCreds(name, creds[name], now); err != nil {
+			return err
+		}
+	}
+
+	var pruned []string
+	if m.pruneExpired {
+		pruned = m.pruneExpiredSections()
+		if len(pruned) > 0 {
+			logrus.Infof("Pruned %d expired profile(s) from %s: %s", len(pruned), path, strings.Join(pruned, ", "))
+		} else {
+			logrus.Infof("No expired profiles to prune in %s", path)
+		}
+	}
+	if len(creds) == 0 && len(pruned) == 0 {
+		return nil
+	}
+
+	// Save the credentials file
+	return m.saveCredsFile()
 }
 
-// updateProfileWithCreds updates (or creates) the profile section with role credentials.
-func (m *awsCredentialsManager) updateProfileWithCreds() error {
-	section, err := m.credsFile.GetSection(m.profileName)
-	if err != nil {
-		section, _ = m.credsFile.NewSection(m.profileName)
+// pruneExpiredSections deletes the sections that carry the import marker and
+// whose recorded expiration has passed, and returns their names.
+func (m *awsCredentialsManager) pruneExpiredSections() []string {
+	var pruned []string
+	for _, section := range m.credsFile.Sections() {
+		if _, ok := m.credsFile.Get(section, credsSourceProfileKey); !ok {
+			continue
+		}
+		if expiration := sectionExpiration(m.credsFile, section); expiration != "" && isExpired(expiration) {
+			pruned = append(pruned, section)
+		}
+	}
+	for _, name := range pruned {
+		m.credsFile.DeleteSection(name)
+	}
+	return pruned
+}
+
+// loadOrInitCredsFile loads the credentials file or initializes an empty one if it doesn't exist.
+// The file is kept as lines, so sections import does not touch are written
+// back byte for byte.
+func (m *awsCredentialsManager) loadOrInitCredsFile() error {
+	data, err := os.ReadFile(m.credentialsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
 	}
+	m.credsFile = inifile.Parse(data)
+	return nil
+}
 
-	section.Key("aws_access_key_id").SetValue(m.roleCred.AccessKeyId)
-	section.Key("aws_secret_access_key").SetValue(m.roleCred.SecretAccessKey)
-	section.Key("aws_session_token").SetValue(m.roleCred.SessionToken)
-	section.Key("aws_security_token").SetValue(m.roleCred.SessionToken)
+// updateProfileWithCreds updates (or creates) the profile section with role
+// credentials, their expiration, the source profile and the import time.
+// Existing keys are rewritten in place; other keys and comments in the
+// section are kept.
+func (m *awsCredentialsManager) updateProfileWithCreds(profileName string, roleCred *model.RoleCredential, now time.Time) error {
+	m.credsFile.Set(profileName, "aws_access_key_id", roleCred.AccessKeyId)
+	m.credsFile.Set(profileName, "aws_secret_access_key", roleCred.SecretAccessKey)
+	m.credsFile.Set(profileName, "aws_session_token", roleCred.SessionToken)
+	m.credsFile.Set(profileName, "aws_security_token", roleCred.SessionToken)
+	if roleCred.Expiration != "" {
+		expiration := credentialExpiration(roleCred.Expiration)
+		m.credsFile.Set(profileName, credsExpirationKey, expiration)
+		m.credsFile.Set(profileName, credsTokenExpiresKey, expiration)
+	}
+	m.credsFile.Set(profileName, credsSourceProfileKey, profileName)
+	m.credsFile.Set(profileName, credsImportedAtKey, now.UTC().Format(time.RFC3339))
 
 	return nil
 }
 
 // saveCredsFile saves the updated credentials file back to the filesystem.
+// The file is written to a temporary sibling and renamed into place; the
+// original mode is kept (0600 for new files). With backupCreds set, the
+// previous contents are first copied to a timestamped backup.
 func (m *awsCredentialsManager) saveCredsFile() error {
-	return m.credsFile.SaveTo(m.credentialsPath)
+	if m.backupCreds {
+		backupPath, err := fsutil.Backup(m.credentialsPath, time.Now())
+		if err != nil {
+			return fmt.Errorf("could not back up credentials file: %w", err)
+		}
+		if backupPath != "" {
+			logrus.Infof("Backed up %s to %s", m.credentialsPath, backupPath)
+		}
+	}
+
+	return fsutil.WriteFileAtomic(m.credentialsPath, m.credsFile.Bytes(), 0o600)
 }
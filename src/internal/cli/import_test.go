@@ -4,9 +4,14 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-ini/ini"
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
 	"github.com/witnsby/aws-sso-login/src/internal/profiles"
 )
 
@@ -25,12 +30,24 @@ func (r *recordingSelector) Pick(p []profiles.Profile) (string, error) {
 }
 
 // swapDefaultSelector replaces defaultSelector for the duration of the test
-// and registers a cleanup hook to restore the original value.
+// and registers a cleanup hook to restore the original value. It also
+// simulates a terminal with AWS_PROFILE unset, so the selector is reachable.
 func swapDefaultSelector(t *testing.T, s Selector) {
 	t.Helper()
 	orig := defaultSelector
 	t.Cleanup(func() { defaultSelector = orig })
 	defaultSelector = s
+	simulateTerminal(t, true)
+}
+
+// simulateTerminal overrides isInteractive and clears AWS_PROFILE for the
+// duration of the test.
+func simulateTerminal(t *testing.T, interactive bool) {
+	t.Helper()
+	orig := isInteractive
+	t.Cleanup(func() { isInteractive = orig })
+	isInteractive = func() bool { return interactive }
+	t.Setenv("AWS_PROFILE", "")
 }
 
 // writeAwsConfig writes the given content to a temp file and points
@@ -103,6 +120,47 @@ sso_role_name = ReadOnly
 	}
 }
 
+// TestResolveProfileName_AWSProfileEnv verifies that AWS_PROFILE is used as
+// the default before prompting, and that --profile still wins over it.
+func TestResolveProfileName_AWSProfileEnv(t *testing.T) {
+	rec := &recordingSelector{inner: fakeSelector{choice: "should-not-be-used"}}
+	swapDefaultSelector(t, rec)
+	t.Setenv("AWS_PROFILE", "from-env")
+
+	got, err := resolveProfileName("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "from-env" {
+		t.Fatalf("expected %q, got %q", "from-env", got)
+	}
+	if got, _ := resolveProfileName("explicit"); got != "explicit" {
+		t.Fatalf("expected --profile to win over AWS_PROFILE, got %q", got)
+	}
+	if rec.calls != 0 {
+		t.Fatalf("expected selector not to be called, got %d calls", rec.calls)
+	}
+}
+
+// TestResolveProfileName_NonInteractive verifies that without a terminal
+// (e.g. `process` run by an SDK) the picker is never shown.
+func TestResolveProfileName_NonInteractive(t *testing.T) {
+	rec := &recordingSelector{inner: fakeSelector{choice: "should-not-be-used"}}
+	swapDefaultSelector(t, rec)
+	simulateTerminal(t, false)
+
+	_, err := resolveProfileName("")
+	if err == nil || err.Error() != helper.ErrorPofileSpecification {
+		t.Fatalf("expected %q, got %v", helper.ErrorPofileSpecification, err)
+	}
+	if _, err := resolveProfileNames(); err == nil {
+		t.Fatal("expected multi-select to be refused without a terminal")
+	}
+	if rec.calls != 0 {
+		t.Fatalf("expected selector not to be called, got %d calls", rec.calls)
+	}
+}
+
 // TestResolveProfileName_NoSSOProfiles verifies that a config with no SSO
 // profiles surfaces a descriptive error and never invokes the selector.
 func TestResolveProfileName_NoSSOProfiles(t *testing.T) {
@@ -153,6 +211,236 @@ sso_role_name = AdministratorAccess
 	}
 }
 
+// TestResolveProfileNames_FromMultiPicker verifies that the multi-select
+// picker receives the SSO profiles and its choices are returned verbatim.
+func TestResolveProfileNames_FromMultiPicker(t *testing.T) {
+	cfg := `[profile dev-account]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+
+[profile sandbox]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = ReadOnly
+`
+	writeAwsConfig(t, cfg)
+
+	orig := defaultMultiSelector
+	t.Cleanup(func() { defaultMultiSelector = orig })
+	defaultMultiSelector = fakeSelector{choices: []string{"dev-account", "sandbox"}}
+	simulateTerminal(t, true)
+
+	got, err := resolveProfileNames()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(got, ",") != "dev-account,sandbox" {
+		t.Fatalf("expected [dev-account sandbox], got %v", got)
+	}
+
+	sentinel := errors.New("user cancelled")
+	defaultMultiSelector = fakeSelector{err: sentinel}
+	if _, err := resolveProfileNames(); !errors.Is(err, sentinel) {
+		t.Fatalf("expected wrapped sentinel error, got %v", err)
+	}
+}
+
+// isolateAwsPaths points the credentials file and both cache directories at
+// a temp dir for the duration of the test and returns that dir.
+func isolateAwsPaths(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv(helper.CredentialsFileEnv, filepath.Join(dir, "credentials"))
+	t.Setenv(helper.CliCacheDirEnv, filepath.Join(dir, "cli", "cache"))
+	t.Setenv(helper.SSOCacheDirEnv, filepath.Join(dir, "sso", "cache"))
+	return dir
+}
+
+// TestImportCreds_HonoursCredentialsFileEnv verifies that import writes to
+// AWS_SHARED_CREDENTIALS_FILE, keeps unrelated sections and creates the file 0600.
+func TestImportCreds_HonoursCredentialsFileEnv(t *testing.T) {
+	writeAwsConfig(t, `[profile dev-account]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+`)
+	dir := isolateAwsPaths(t)
+	credsPath := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(credsPath, []byte("[other]\naws_access_key_id = AKIAOTHER\n"), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	useStaticSource(t, StaticSource{Credential: &model.RoleCredential{
+		AccessKeyId:     "AKIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		Expiration:      "2099-01-01T00:00:00Z",
+	}})
+
+	if err := importCreds("dev-account", importOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(credsPath)
+	if err != nil {
+		t.Fatalf("read credentials: %v", err)
+	}
+	for _, want := range []string{"[other]", "AKIAOTHER", "[dev-account]"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("credentials file missing %q:\n%s", want, data)
+		}
+	}
+	if !regexp.MustCompile(`aws_access_key_id\s*= AKIAEXAMPLE`).Match(data) {
+		t.Fatalf("credentials file missing the imported access key:\n%s", data)
+	}
+	info, err := os.Stat(credsPath)
+	if err != nil {
+		t.Fatalf("stat credentials: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("credentials mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+// TestImportCreds_MetadataSkipAndForce verifies the expiration, source
+// profile and import time keys, that a still-valid section is not refreshed,
+// and that --force refreshes it anyway.
+func TestImportCreds_MetadataSkipAndForce(t *testing.T) {
+	writeAwsConfig(t, `[profile dev-account]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+`)
+	dir := isolateAwsPaths(t)
+	credsPath := filepath.Join(dir, "credentials")
+	t.Setenv(refreshWindowEnv, "")
+	useStaticSource(t, StaticSource{Credential: &model.RoleCredential{
+		AccessKeyId: "AKIAFIRST", SecretAccessKey: "secret", SessionToken: "token",
+		Expiration: "2099-01-01T00:00:00UTC",
+	}})
+	if err := importCreds("dev-account", importOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg, err := ini.Load(credsPath)
+	if err != nil {
+		t.Fatalf("load credentials: %v", err)
+	}
+	section := cfg.Section("dev-account")
+	for key, want := range map[string]string{
+		credsExpirationKey:    "2099-01-01T00:00:00Z",
+		credsTokenExpiresKey:  "2099-01-01T00:00:00Z",
+		credsSourceProfileKey: "dev-account",
+	} {
+		if got := section.Key(key).String(); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, section.Key(credsImportedAtKey).String()); err != nil {
+		t.Errorf("%s is not an RFC 3339 time: %v", credsImportedAtKey, err)
+	}
+
+	useStaticSource(t, StaticSource{Credential: &model.RoleCredential{
+		AccessKeyId: "AKIASECOND", SecretAccessKey: "secret", SessionToken: "token",
+		Expiration: "2099-01-01T00:00:00Z",
+	}})
+	if err := importCreds("dev-account", importOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(credsPath); !strings.Contains(string(data), "AKIAFIRST") {
+		t.Fatalf("expected still-valid credentials to be kept:\n%s", data)
+	}
+
+	if err := importCreds("dev-account", importOptions{force: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(credsPath); !strings.Contains(string(data), "AKIASECOND") {
+		t.Fatalf("expected --force to refresh the credentials:\n%s", data)
+	}
+}
+
+// TestImportCreds_RefreshesUnmarkedSection verifies that a section without
+// the import marker, or marked for another profile, is refreshed even when it
+// records a future expiration.
+func TestImportCreds_RefreshesUnmarkedSection(t *testing.T) {
+	writeAwsConfig(t, `[profile dev-account]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+`)
+	dir := isolateAwsPaths(t)
+	credsPath := filepath.Join(dir, "credentials")
+	t.Setenv(refreshWindowEnv, "")
+	useStaticSource(t, StaticSource{Credential: &model.RoleCredential{
+		AccessKeyId: "AKIAIMPORTED", SecretAccessKey: "secret", SessionToken: "token",
+		Expiration: "2099-01-01T00:00:00Z",
+	}})
+
+	for name, marker := range map[string]string{
+		"unmarked":      "",
+		"other profile": credsSourceProfileKey + " = prod-account\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			fixture := "[dev-account]\naws_access_key_id = AKIAHANDWRITTEN\n" +
+				credsTokenExpiresKey + " = 2099-01-01T00:00:00Z\n" + marker
+			if err := os.WriteFile(credsPath, []byte(fixture), 0o600); err != nil {
+				t.Fatalf("write fixture: %v", err)
+			}
+			if err := importCreds("dev-account", importOptions{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, _ := os.ReadFile(credsPath)
+			if !strings.Contains(string(data), "AKIAIMPORTED") || strings.Contains(string(data), "AKIAHANDWRITTEN") {
+				t.Fatalf("expected the section to be refreshed:\n%s", data)
+			}
+		})
+	}
+}
+
+// TestPruneCreds verifies that only expired sections carrying the import
+// marker are removed.
+func TestPruneCreds(t *testing.T) {
+	dir := isolateAwsPaths(t)
+	credsPath := filepath.Join(dir, "credentials")
+	fixture := `[manual]
+aws_access_key_id = AKIAMANUAL
+aws_expiration = 2000-01-01T00:00:00Z
+
+[stale]
+aws_access_key_id = AKIASTALE
+aws_expiration = 2000-01-01T00:00:00Z
+sso_login_source_profile = stale
+
+[fresh]
+aws_access_key_id = AKIAFRESH
+x_security_token_expires = 2099-01-01T00:00:00Z
+sso_login_source_profile = fresh
+`
+	if err := os.WriteFile(credsPath, []byte(fixture), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	if err := pruneCreds(importOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(credsPath)
+	if err != nil {
+		t.Fatalf("read credentials: %v", err)
+	}
+	if strings.Contains(string(data), "[stale]") {
+		t.Fatalf("expected [stale] to be pruned:\n%s", data)
+	}
+	for _, want := range []string{"[manual]", "[fresh]"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("expected %s to be kept:\n%s", want, data)
+		}
+	}
+}
+
 func contains(haystack []string, needle string) bool {
 	for _, s := range haystack {
 		if s == needle {
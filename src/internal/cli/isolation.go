@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/go-ini/ini"
+)
+
+const (
+	// browserIsolationEnv selects the isolation strategy when --isolation is not set.
+	browserIsolationEnv = "AWS_SSO_LOGIN_BROWSER_ISOLATION"
+	// browserIsolationKey selects the isolation strategy per profile in ~/.aws/config.
+	browserIsolationKey = "sso_login_browser_isolation"
+	// browserKey sets the --browser command template per profile in ~/.aws/config.
+	browserKey = "sso_login_browser"
+
+	// isolationNone shares one browser session; console logs out first.
+	isolationNone = "none"
+	// isolationFirefoxContainer opens ext+container: URLs, one container per profile.
+	isolationFirefoxContainer = "firefox-container"
+	// isolationChromium launches Chromium with a per-profile --user-data-dir.
+	isolationChromium = "chromium"
+)
+
+// containerColors are the colours supported by Firefox Multi-Account Containers.
+var containerColors = []string{"blue", "turquoise", "green", "yellow", "orange", "red", "pink", "purple"}
+
+// containerIcon is the icon used for every generated container.
+const containerIcon = "fingerprint"
+
+// unsafePathChars matches characters not used verbatim in per-profile directory names.
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// resolveBrowserIsolation picks the isolation strategy: the --isolation flag,
+// else AWS_SSO_LOGIN_BROWSER_ISOLATION, else the profile's
+// sso_login_browser_isolation key, else "none".
+func resolveBrowserIsolation(flagValue string, profile *ini.Section) (string, error) {
+	name := flagValue
+	if name == "" {
+		name = os.Getenv(browserIsolationEnv)
+	}
+	if name == "" && profile != nil {
+		name = profile.Key(browserIsolationKey).String()
+	}
+	switch name {
+	case "", isolationNone:
+		return isolationNone, nil
+	case isolationFirefoxContainer, isolationChromium:
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown browser isolation %q (valid: %s, %s, %s)",
+		name, isolationChromium, isolationFirefoxContainer, isolationNone)
+}
+
+// resolveBrowserCommand returns the --browser flag value, else the profile's
+// sso_login_browser key.
+func resolveBrowserCommand(flagValue string, profile *ini.Section) string {
+	if flagValue != "" || profile == nil {
+		return flagValue
+	}
+	return profile.Key(browserKey).String()
+}
+
+// isolatedOpener returns the function used to open console URLs for
+// profileName under the given isolation strategy. template is the browser
+// command (see browserOpener); isolated strategies fall back to a
+// platform-specific Firefox or Chrome command when it is empty.
+func isolatedOpener(isolation, template, profileName string) (func(string) error, error) {
+	switch isolation {
+	case isolationFirefoxContainer:
+		if template == "" {
+			template = defaultFirefoxCommand()
+		}
+		open, err := browserOpener(template)
+		if err != nil {
+			return nil, err
+		}
+		return func(targetURL string) error {
+			return open(containerURL(profileName, targetURL))
+		}, nil
+
+	case isolationChromium:
+		if template == "" {
+			template = defaultChromiumCommand()
+		}
+		args, err := splitCommandLine(template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --browser command %q: %w", template, err)
+		}
+		dir, err := chromiumUserDataDir(profileName)
+		if err != nil {
+			return nil, err
+		}
+		return func(targetURL string) error {
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return fmt.Errorf("failed to create browser profile directory: %w", err)
+			}
+			return startCommand(browserCommandArgs(withChromiumProfile(args, dir), targetURL))
+		}, nil
+	}
+	return browserOpener(template)
+}
+
+// containerURL wraps targetURL in an ext+container: URL understood by the
+// "Open external links in a container" Firefox extension. The container is
+// named after the profile and gets a colour derived from the name, so each
+// profile always opens in the same container.
+func containerURL(profileName, targetURL string) string {
+	return fmt.Sprintf("ext+container:name=%s&color=%s&icon=%s&url=%s",
+		url.QueryEscape(profileName), containerColor(profileName), containerIcon, url.QueryEscape(targetURL))
+}
+
+// containerColor deterministically maps a profile name onto a container colour.
+func containerColor(profileName string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(profileName))
+	return containerColors[h.Sum32()%uint32(len(containerColors))]
+}
+
+// chromiumUserDataDir returns the per-profile Chromium user data directory,
+// <user config dir>/aws-sso-login/chromium/<profile>.
+func chromiumUserDataDir(profileName string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine user config directory: %w", err)
+	}
+	return filepath.Join(configDir, "aws-sso-login", "chromium", unsafePathChars.ReplaceAllString(profileName, "_")), nil
+}
+
+// withChromiumProfile inserts the --user-data-dir flag before the {url}
+// argument, or at the end when the template has no placeholder.
+func withChromiumProfile(args []string, dir string) []string {
+	flags := []string{"--user-data-dir=" + dir, "--no-first-run"}
+	out := make([]string, 0, len(args)+len(flags))
+	inserted := false
+	for _, arg := range args {
+		if !inserted && strings.Contains(arg, browserURLPlaceholder) {
+			out = append(out, flags...)
+			inserted = true
+		}
+		out = append(out, arg)
+	}
+	if !inserted {
+		out = append(out, flags...)
+	}
+	return out
+}
+
+// defaultFirefoxCommand returns the command used to launch Firefox.
+func defaultFirefoxCommand() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "open -a Firefox"
+	case "windows":
+		return `"C:\\Program Files\\Mozilla Firefox\\firefox.exe"`
+	}
+	return "firefox"
+}
+
+// defaultChromiumCommand returns the command used to launch Chrome/Chromium.
+func defaultChromiumCommand() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return `open -na "Google Chrome" --args`
+	case "windows":
+		return `"C:\\Program Files\\Google\\Chrome\\Application\\chrome.exe"`
+	}
+	return "chromium"
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-ini/ini"
+)
+
+func TestResolveBrowserIsolation(t *testing.T) {
+	cfg, err := ini.Load([]byte(`[profile dev]
+sso_login_browser_isolation = chromium
+sso_login_browser = brave-browser
+
+[profile plain]
+`))
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	dev, plain := cfg.Section("profile dev"), cfg.Section("profile plain")
+
+	cases := []struct {
+		name    string
+		flag    string
+		env     string
+		profile *ini.Section
+		want    string
+	}{
+		{name: "default", profile: plain, want: isolationNone},
+		{name: "profile key", profile: dev, want: isolationChromium},
+		{name: "env beats profile", env: isolationFirefoxContainer, profile: dev, want: isolationFirefoxContainer},
+		{name: "flag beats env", flag: isolationNone, env: isolationFirefoxContainer, profile: dev, want: isolationNone},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(browserIsolationEnv, tc.env)
+			got, err := resolveBrowserIsolation(tc.flag, tc.profile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	t.Setenv(browserIsolationEnv, "")
+	if _, err := resolveBrowserIsolation("safari", plain); err == nil {
+		t.Fatal("expected an error for an unknown isolation strategy")
+	}
+
+	if got := resolveBrowserCommand("", dev); got != "brave-browser" {
+		t.Fatalf("expected profile browser command, got %q", got)
+	}
+	if got := resolveBrowserCommand("firefox", dev); got != "firefox" {
+		t.Fatalf("expected --browser to win, got %q", got)
+	}
+}
+
+func TestContainerURL(t *testing.T) {
+	got := containerURL("dev account", "https://signin.aws.amazon.com/federation?Action=login&SigninToken=x")
+	want := "ext+container:name=dev+account&color=" + containerColor("dev account") +
+		"&icon=fingerprint&url=https%3A%2F%2Fsignin.aws.amazon.com%2Ffederation%3FAction%3Dlogin%26SigninToken%3Dx"
+	if got != want {
+		t.Fatalf("containerURL() = %q, want %q", got, want)
+	}
+
+	// Colours are stable per profile and spread across profiles.
+	seen := map[string]bool{}
+	for _, name := range []string{"dev", "staging", "prod", "sandbox", "audit", "network", "shared", "logs"} {
+		if containerColor(name) != containerColor(name) {
+			t.Fatalf("colour for %q is not deterministic", name)
+		}
+		seen[containerColor(name)] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("expected different profiles to get different colours, got %v", seen)
+	}
+}
+
+func TestIsolatedOpener(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("HOME", configDir)
+
+	var argv []string
+	execCommand = func(name string, arg ...string) *exec.Cmd {
+		argv = append([]string{name}, arg...)
+		return exec.Command("true")
+	}
+	defer func() { execCommand = exec.Command }()
+
+	open, err := isolatedOpener(isolationFirefoxContainer, "firefox", "dev")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := open("https://example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(argv) != 2 || argv[0] != "firefox" || !strings.HasPrefix(argv[1], "ext+container:name=dev&") {
+		t.Fatalf("unexpected firefox command %q", argv)
+	}
+
+	open, err = isolatedOpener(isolationChromium, "google-chrome --new-window {url}", "team/prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := open("https://example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dir, err := chromiumUserDataDir("team/prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Base(dir) != "team_prod" {
+		t.Fatalf("expected a sanitised directory name, got %q", dir)
+	}
+	want := []string{"google-chrome", "--new-window", "--user-data-dir=" + dir, "--no-first-run", "https://example.com"}
+	if strings.Join(argv, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected chromium command %q, want %q", argv, want)
+	}
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/witnsby/aws-sso-login/src/internal/logging"
+)
+
+const (
+	// logLevelEnv sets the log level when --log-level is not set.
+	logLevelEnv = "AWS_SSO_LOGIN_LOG_LEVEL"
+	// logFormatEnv sets the log format when --log-format is not set.
+	logFormatEnv = "AWS_SSO_LOGIN_LOG_FORMAT"
+
+	defaultLogLevel = "info"
+	// quietLogLevel is the default for process, whose stderr is shown by the
+	// SDKs that call it on every credential refresh.
+	quietLogLevel = "warn"
+)
+
+// logLevelFlag and logFormatFlag hold the global --log-level and --log-format flags.
+var (
+	logLevelFlag  string
+	logFormatFlag string
+)
+
+// configureLogging applies the log level and format for cmd to the standard
+// logrus logger. The flags win over AWS_SSO_LOGIN_LOG_LEVEL and
+// AWS_SSO_LOGIN_LOG_FORMAT; the defaults are info (warn for process) and text.
+func configureLogging(cmd *cobra.Command) error {
+	level := logLevelFlag
+	if level == "" {
+		level = os.Getenv(logLevelEnv)
+	}
+	if level == "" {
+		level = defaultLogLevel
+		if cmd == processCmd {
+			level = quietLogLevel
+		}
+	}
+
+	format := logFormatFlag
+	if format == "" {
+		format = os.Getenv(logFormatEnv)
+	}
+	if format == "" {
+		format = logging.FormatText
+	}
+
+	return logging.Configure(logrus.StandardLogger(), level, format)
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TestConfigureLogging verifies the flag > environment > default precedence
+// of the log level and format, and the quiet default for process.
+func TestConfigureLogging(t *testing.T) {
+	logger := logrus.StandardLogger()
+	origLevel, origFormatter, origOut := logger.GetLevel(), logger.Formatter, logger.Out
+	origLevelFlag, origFormatFlag := logLevelFlag, logFormatFlag
+	t.Cleanup(func() {
+		logger.SetLevel(origLevel)
+		logger.SetFormatter(origFormatter)
+		logger.SetOutput(origOut)
+		logLevelFlag, logFormatFlag = origLevelFlag, origFormatFlag
+	})
+
+	cases := []struct {
+		name       string
+		flag, env  string
+		cmdIsProc  bool
+		wantLevel  logrus.Level
+		formatFlag string
+		formatEnv  string
+		wantJSON   bool
+	}{
+		{name: "default", wantLevel: logrus.InfoLevel},
+		{name: "process is quiet", cmdIsProc: true, wantLevel: logrus.WarnLevel},
+		{name: "env", env: "debug", cmdIsProc: true, wantLevel: logrus.DebugLevel},
+		{name: "flag wins", flag: "error", env: "debug", wantLevel: logrus.ErrorLevel},
+		{name: "json from env", formatEnv: "json", wantLevel: logrus.InfoLevel, wantJSON: true},
+		{name: "format flag wins", formatFlag: "text", formatEnv: "json", wantLevel: logrus.InfoLevel},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			logLevelFlag, logFormatFlag = tc.flag, tc.formatFlag
+			t.Setenv(logLevelEnv, tc.env)
+			t.Setenv(logFormatEnv, tc.formatEnv)
+			cmd := consoleCmd
+			if tc.cmdIsProc {
+				cmd = processCmd
+			}
+			if err := configureLogging(cmd); err != nil {
+				t.Fatalf("configureLogging: %v", err)
+			}
+			if got := logger.GetLevel(); got != tc.wantLevel {
+				t.Errorf("level = %s, want %s", got, tc.wantLevel)
+			}
+
+			var buf bytes.Buffer
+			logger.SetOutput(&buf)
+			logger.Error("probe")
+			if isJSON := strings.HasPrefix(buf.String(), "{"); isJSON != tc.wantJSON {
+				t.Errorf("JSON output = %v, want %v: %s", isJSON, tc.wantJSON, buf.String())
+			}
+		})
+	}
+}
+
+// TestConfigureLogging_Invalid verifies that a bad level or format is an error.
+func TestConfigureLogging_Invalid(t *testing.T) {
+	origLevelFlag, origFormatFlag := logLevelFlag, logFormatFlag
+	t.Cleanup(func() { logLevelFlag, logFormatFlag = origLevelFlag, origFormatFlag })
+
+	logLevelFlag, logFormatFlag = "", ""
+	t.Setenv(logLevelEnv, "loud")
+	if err := configureLogging(consoleCmd); err == nil {
+		t.Error("expected an error for an invalid level")
+	}
+	t.Setenv(logLevelEnv, "")
+	logFormatFlag = "xml"
+	if err := configureLogging(consoleCmd); err == nil {
+		t.Error("expected an error for an invalid format")
+	}
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-ini/ini"
+	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+	"github.com/witnsby/aws-sso-login/src/internal/redact"
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
+)
+
+// ssoClientName is the OIDC client name registered for the device flow.
+const ssoClientName = "aws-sso-login"
+
+// errSSOLoginRequired indicates that no valid SSO access token is cached for
+// the profile. It is recoverable by running the device authorization flow.
+var errSSOLoginRequired = errors.New("SSO login required")
+
+// By default, these point to the real SSO client and the device flow login.
+var (
+	newSSOClient = sso.NewClient
+	// runSSOLogin is the login entry point used by the commands.
+	runSSOLogin = ssoLogin
+)
+
+// ssoLogin runs the SSO OIDC device authorization flow for the profile and
+// stores the resulting access token in ~/.aws/sso/cache in the same format as
+// `aws sso login`. A still-valid client registration from a previous login is
+// reused. Instructions are written to stderr so stdout stays machine-readable.
+func ssoLogin(profile *ini.Section) error {
+	ctx := context.Background()
+	region := profile.Key("sso_region").String()
+	startURL := profile.Key("sso_start_url").String()
+	sessionName := profile.Key("sso_session").String()
+
+	cacheDir, err := helper.GetAwsSSOCachePath()
+	if err != nil {
+		return err
+	}
+	cacheKey := sso.TokenCacheKey(sessionName, startURL)
+	client := newSSOClient(region)
+	now := time.Now()
+
+	token := &sso.CachedToken{StartURL: startURL, Region: region}
+	if cached, err := sso.LoadToken(cacheDir, cacheKey); err == nil && cached.RegistrationValid(now) {
+		token.ClientID = cached.ClientID
+		token.ClientSecret = cached.ClientSecret
+		token.RegistrationExpiresAt = cached.RegistrationExpiresAt
+	} else {
+		var scopes []string
+		if raw := profile.Key("sso_registration_scopes").String(); raw != "" {
+			for _, scope := range strings.Split(raw, ",") {
+				scopes = append(scopes, strings.TrimSpace(scope))
+			}
+		}
+		reg, err := client.RegisterClient(ctx, ssoClientName, scopes)
+		if err != nil {
+			return fmt.Errorf("registering SSO OIDC client: %w", err)
+		}
+		token.ClientID = reg.ClientID
+		token.ClientSecret = reg.ClientSecret
+		token.RegistrationExpiresAt = time.Unix(reg.ClientSecretExpiresAt, 0).UTC().Format(sso.TimeFormat)
+	}
+
+	reg := &sso.RegisterClientOutput{ClientID: token.ClientID, ClientSecret: token.ClientSecret}
+	auth, err := client.StartDeviceAuthorization(ctx, reg.ClientID, reg.ClientSecret, startURL)
+	if err != nil {
+		return fmt.Errorf("starting SSO device authorization: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Attempting to open the SSO authorization page in your browser.\n"+
+		"If it does not open, visit:\n\n%s\n\nand enter the code:\n\n%s\n\n",
+		auth.VerificationURI, auth.UserCode)
+	startBrowser(auth.VerificationURIComplete)
+
+	created, err := client.WaitForToken(ctx, reg, auth)
+	if err != nil {
+		return fmt.Errorf("waiting for SSO authorization: %w", err)
+	}
+	token.AccessToken = created.AccessToken
+	token.RefreshToken = created.RefreshToken
+	redact.Register(token.AccessToken, token.RefreshToken, token.ClientSecret)
+	token.ExpiresAt = time.Now().Add(time.Duration(created.ExpiresIn) * time.Second).UTC().Format(sso.TimeFormat)
+
+	if err := sso.SaveToken(cacheDir, cacheKey, token); err != nil {
+		return fmt.Errorf("saving SSO token: %w", err)
+	}
+	logrus.Infof("Successfully logged in to %s", startURL)
+	return nil
+}
+
+// fetchRoleCredentials exchanges the cached SSO access token for role
+// credentials through the SSO portal GetRoleCredentials API.
+//
+// A missing, expired or rejected access token is reported as
+// errSSOLoginRequired; a ForbiddenException is reported as errSSORoleNoAccess.
+func fetchRoleCredentials(profileName string, profile *ini.Section) (*model.RoleCredential, error) {
+	accessToken, err := loadSSOAccessToken(profileName, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newSSOClient(profile.Key("sso_region").String())
+	creds, err := client.GetRoleCredentials(context.Background(), accessToken,
+		profile.Key("sso_account_id").String(), profile.Key("sso_role_name").String())
+	if err != nil {
+		return nil, classifySSOError(profileName, err)
+	}
+
+	return &model.RoleCredential{
+		AccessKeyId:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      time.UnixMilli(creds.Expiration).UTC().Format(sso.TimeFormat),
+	}, nil
+}
+
+// loadSSOAccessToken returns the cached SSO access token for the profile's
+// sso-session or start URL. A missing or expired token is reported as
+// errSSOLoginRequired.
+func loadSSOAccessToken(profileName string, profile *ini.Section) (string, error) {
+	cacheDir, err := helper.GetAwsSSOCachePath()
+	if err != nil {
+		return "", err
+	}
+	cacheKey := sso.TokenCacheKey(profile.Key("sso_session").String(), profile.Key("sso_start_url").String())
+	token, err := sso.LoadToken(cacheDir, cacheKey)
+	if err != nil || !token.Valid(time.Now()) {
+		return "", fmt.Errorf("%w for profile %q", errSSOLoginRequired, profileName)
+	}
+	redact.Register(token.AccessToken)
+	return token.AccessToken, nil
+}
+
+// classifySSOError maps SSO portal errors onto the sentinels the retry logic
+// in retrieveAndSetProfile understands.
+func classifySSOError(profileName string, err error) error {
+	var apiErr *sso.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	switch apiErr.Code {
+	case "ForbiddenException", "AccessDeniedException":
+		return fmt.Errorf("%w for profile %q: %v", errSSORoleNoAccess, profileName, err)
+	case "UnauthorizedException", "InvalidGrantException", "ExpiredTokenException":
+		return fmt.Errorf("%w for profile %q: %v", errSSOLoginRequired, profileName, err)
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-ini/ini"
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
+)
+
+// fakeOIDC serves RegisterClient, StartDeviceAuthorization and CreateToken,
+// approving the device on the first poll. It records the calls per path and
+// the scopes of the last registration.
+type fakeOIDC struct {
+	calls  map[string]int
+	scopes []string
+}
+
+func (f *fakeOIDC) install(t *testing.T) {
+	t.Helper()
+	f.calls = map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.calls[r.URL.Path]++
+		var in map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		switch r.URL.Path {
+		case "/client/register":
+			f.scopes = nil
+			if raw, ok := in["scopes"].([]any); ok {
+				for _, s := range raw {
+					f.scopes = append(f.scopes, s.(string))
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"clientId":              "client-id",
+				"clientSecret":          "client-secret",
+				"clientSecretExpiresAt": time.Now().Add(90 * 24 * time.Hour).Unix(),
+			})
+		case "/device_authorization":
+			if in["clientId"] != "client-id" || in["clientSecret"] != "client-secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"deviceCode":              "device-code",
+				"userCode":                "ABCD-EFGH",
+				"verificationUri":         "https://device.sso.us-east-1.amazonaws.com/",
+				"verificationUriComplete": "https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH",
+				"expiresIn":               600,
+				"interval":                1,
+			})
+		case "/token":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"accessToken":  "access-token",
+				"tokenType":    "Bearer",
+				"expiresIn":    3600,
+				"refreshToken": "refresh-token",
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	orig := newSSOClient
+	t.Cleanup(func() { newSSOClient = orig })
+	newSSOClient = func(string) *sso.Client {
+		return &sso.Client{OIDCEndpoint: srv.URL, PortalEndpoint: srv.URL, HTTPClient: srv.Client()}
+	}
+}
+
+// TestSSOLogin_SavesTokenAndReusesRegistration runs the device flow twice:
+// the first login registers a client with the profile's scopes, the second
+// reuses the cached registration. The token is stored under TokenCacheKey in
+// the AWS CLI's format.
+func TestSSOLogin_SavesTokenAndReusesRegistration(t *testing.T) {
+	dir := isolateAwsPaths(t)
+	oidc := &fakeOIDC{}
+	oidc.install(t)
+	origExec := execCommand
+	t.Cleanup(func() { execCommand = origExec })
+	execCommand = func(string, ...string) *exec.Cmd { return exec.Command("true") }
+
+	cfg, err := ini.Load([]byte(`[profile dev]
+sso_session = corp
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_registration_scopes = sso:account:access, codewhisperer:completions
+`))
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	profile := cfg.Section("profile dev")
+
+	if err := ssoLogin(profile); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if oidc.calls["/client/register"] != 1 {
+		t.Fatalf("expected one registration, got %d", oidc.calls["/client/register"])
+	}
+	if want := []string{"sso:account:access", "codewhisperer:completions"}; !reflect.DeepEqual(oidc.scopes, want) {
+		t.Errorf("scopes = %q, want %q", oidc.scopes, want)
+	}
+
+	path := filepath.Join(dir, "sso", "cache", sso.TokenCacheKey("corp", "")+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read token cache: %v", err)
+	}
+	var cached map[string]string
+	if err := json.Unmarshal(data, &cached); err != nil {
+		t.Fatalf("decode token cache: %v", err)
+	}
+	for key, want := range map[string]string{
+		"startUrl":     "https://example.awsapps.com/start",
+		"region":       "us-east-1",
+		"accessToken":  "access-token",
+		"refreshToken": "refresh-token",
+		"clientId":     "client-id",
+		"clientSecret": "client-secret",
+	} {
+		if cached[key] != want {
+			t.Errorf("%s = %q, want %q", key, cached[key], want)
+		}
+	}
+	for _, key := range []string{"expiresAt", "registrationExpiresAt"} {
+		if _, err := time.Parse(sso.TimeFormat, cached[key]); err != nil {
+			t.Errorf("%s = %q is not in the CLI's time format", key, cached[key])
+		}
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0o600 {
+		t.Errorf("token cache mode = %o, want 600", info.Mode().Perm())
+	}
+
+	if err := ssoLogin(profile); err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if oidc.calls["/client/register"] != 1 {
+		t.Errorf("expected the cached registration to be reused, got %d registrations", oidc.calls["/client/register"])
+	}
+	if oidc.calls["/device_authorization"] != 2 || oidc.calls["/token"] != 2 {
+		t.Errorf("expected a device flow per login, got %v", oidc.calls)
+	}
+}
@@ -3,10 +3,16 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+
 	"github.com/sirupsen/logrus"
 	"github.com/witnsby/aws-sso-login/src/internal/model"
 )
 
+// processOutput receives the credential process JSON.
+var processOutput io.Writer = os.Stdout
+
 // processCreds processes credentials for a given profile and return a JSON output.
 func processCreds(profileName string) error {
 	logrus.Infof("Processing credentials for profile: %s", profileName)
@@ -20,7 +26,7 @@ func processCreds(profileName string) error {
 	logrus.Infof("Successfully retrieved profile for %s", profileName)
 
 	// Get role credentials
-	roleCred, err := getRoleCredentials(profileName, profile, true)
+	roleCred, err := getRoleCredentials(profileName, profile)
 	if err != nil {
 		logrus.WithError(err).Errorf("Failed to get role credentials for profile: %s", profileName)
 		return fmt.Errorf("failed to get role credentials: %w", err)
@@ -35,7 +41,7 @@ func processCreds(profileName string) error {
 		return fmt.Errorf("failed to marshal credentials: %w", err)
 	}
 	logrus.Infof("Successfully marshaled credentials for profile: %s", profileName)
-	fmt.Println(string(payload))
+	fmt.Fprintln(processOutput, string(payload))
 
 	return nil
 }
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-ini/ini"
+)
+
+const (
+	// refreshWindowEnv sets the refresh window when --refresh-window is not set.
+	refreshWindowEnv = "AWS_SSO_LOGIN_REFRESH_WINDOW"
+	// refreshWindowKey sets the refresh window per profile in ~/.aws/config.
+	refreshWindowKey = "sso_login_refresh_window"
+)
+
+// refreshWindowFlag holds the value of the global --refresh-window flag.
+var refreshWindowFlag string
+
+// resolveRefreshWindow returns the minimum remaining lifetime cached
+// credentials must have to be reused. The --refresh-window flag wins over
+// AWS_SSO_LOGIN_REFRESH_WINDOW, which wins over the profile's
+// sso_login_refresh_window key. The default is 0 (reuse until expiry).
+func resolveRefreshWindow(profile *ini.Section) (time.Duration, error) {
+	raw, origin := refreshWindowFlag, "--refresh-window"
+	if raw == "" {
+		raw, origin = os.Getenv(refreshWindowEnv), refreshWindowEnv
+	}
+	if raw == "" && profile != nil {
+		raw, origin = profile.Key(refreshWindowKey).String(), refreshWindowKey
+	}
+	if raw == "" {
+		return 0, nil
+	}
+
+	window, err := parseDurationOrSeconds(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", origin, raw, err)
+	}
+	return window, nil
+}
+
+// parseDurationOrSeconds parses a Go duration ("15m", "1h30m") or a plain
+// number of seconds ("900"). Negative values are rejected.
+func parseDurationOrSeconds(raw string) (time.Duration, error) {
+	var d time.Duration
+	if secs, err := strconv.Atoi(raw); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if d, err = time.ParseDuration(raw); err != nil {
+		return 0, fmt.Errorf("expected a duration such as 15m or a number of seconds")
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration must not be negative")
+	}
+	return d, nil
+}
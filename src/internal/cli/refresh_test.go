@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ini/ini"
+)
+
+func TestResolveRefreshWindow(t *testing.T) {
+	cfg, err := ini.Load([]byte(`[profile p]
+sso_login_refresh_window = 10m
+`))
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	profile := cfg.Section("profile p")
+	origFlag := refreshWindowFlag
+	t.Cleanup(func() { refreshWindowFlag = origFlag })
+
+	cases := []struct {
+		name    string
+		flag    string
+		env     string
+		profile *ini.Section
+		want    time.Duration
+		wantErr string
+	}{
+		{name: "default is zero"},
+		{name: "profile key", profile: profile, want: 10 * time.Minute},
+		{name: "env beats profile", env: "300", profile: profile, want: 5 * time.Minute},
+		{name: "flag beats env", flag: "1h", env: "300", profile: profile, want: time.Hour},
+		{name: "invalid value names its origin", env: "soon", wantErr: `invalid AWS_SSO_LOGIN_REFRESH_WINDOW "soon"`},
+		{name: "negative value", flag: "-5m", wantErr: "must not be negative"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			refreshWindowFlag = tc.flag
+			t.Setenv(refreshWindowEnv, tc.env)
+
+			got, err := resolveRefreshWindow(tc.profile)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("resolveRefreshWindow() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpiresWithin(t *testing.T) {
+	inFiveSeconds := time.Now().Add(5 * time.Second).UTC().Format(time.RFC3339)
+	inOneHour := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if isExpired(inFiveSeconds) {
+		t.Fatal("credentials expiring in 5s should not count as expired")
+	}
+	if !expiresWithin(inFiveSeconds, time.Minute) {
+		t.Fatal("credentials expiring in 5s should fall inside a 1m window")
+	}
+	if expiresWithin(inOneHour, 15*time.Minute) {
+		t.Fatal("credentials expiring in 1h should not fall inside a 15m window")
+	}
+	if !expiresWithin("not-a-timestamp", 0) {
+		t.Fatal("unparsable expiration should be treated as expired")
+	}
+}
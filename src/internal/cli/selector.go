@@ -3,11 +3,90 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"os"
 
 	"github.com/charmbracelet/huh"
+	"github.com/mattn/go-isatty"
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
 	"github.com/witnsby/aws-sso-login/src/internal/profiles"
 )
 
+// By default, these point to the huh pickers and the real terminal check.
+var (
+	// defaultSelector picks the profile when --profile is omitted.
+	defaultSelector Selector = HuhSelector{}
+	// defaultMultiSelector picks the profiles for `import --multi`.
+	defaultMultiSelector MultiSelector = HuhSelector{}
+	// isInteractive reports whether both stdin and stdout are terminals.
+	isInteractive = func() bool {
+		return isTerminal(os.Stdin.Fd()) && isTerminal(os.Stdout.Fd())
+	}
+)
+
+func isTerminal(fd uintptr) bool {
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// resolveProfileName returns the AWS profile name a command operates on.
+//
+// If flagValue is non-empty, it is returned verbatim (the explicit --profile
+// flag bypasses the interactive selector). Otherwise AWS_PROFILE is used when
+// set. Failing both, and only when running in a terminal, the AWS config file
+// is parsed for SSO-enabled profiles and the user is prompted via
+// defaultSelector. Without a terminal (e.g. `process` invoked by an SDK) the
+// profile must be given explicitly.
+func resolveProfileName(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if envValue := os.Getenv("AWS_PROFILE"); envValue != "" {
+		return envValue, nil
+	}
+	if !isInteractive() {
+		return "", errors.New(helper.ErrorPofileSpecification)
+	}
+
+	configPath, err := helper.GetAwsConfigPath()
+	if err != nil {
+		return "", fmt.Errorf("could not determine AWS config path: %w", err)
+	}
+
+	ssoProfiles, err := profiles.ListSSOProfiles(configPath)
+	if err != nil {
+		return "", fmt.Errorf("could not list SSO profiles: %w", err)
+	}
+
+	name, err := defaultSelector.Pick(ssoProfiles)
+	if err != nil {
+		return "", fmt.Errorf("could not select profile: %w", err)
+	}
+	return name, nil
+}
+
+// resolveProfileNames prompts the user via defaultMultiSelector to choose any
+// number of SSO-enabled profiles from the AWS config file. It requires a terminal.
+func resolveProfileNames() ([]string, error) {
+	if !isInteractive() {
+		return nil, errors.New(helper.ErrorPofileSpecification)
+	}
+
+	configPath, err := helper.GetAwsConfigPath()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine AWS config path: %w", err)
+	}
+
+	ssoProfiles, err := profiles.ListSSOProfiles(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not list SSO profiles: %w", err)
+	}
+
+	names, err := defaultMultiSelector.PickMany(ssoProfiles)
+	if err != nil {
+		return nil, fmt.Errorf("could not select profiles: %w", err)
+	}
+	return names, nil
+}
+
 // Selector picks an AWS SSO profile from a list. Implementations may render
 // an interactive UI or be replaced with a deterministic fake in tests.
 type Selector interface {
@@ -15,11 +94,20 @@ type Selector interface {
 	Pick(profiles []profiles.Profile) (string, error)
 }
 
+// MultiSelector picks any number of AWS SSO profiles from a list.
+type MultiSelector interface {
+	// PickMany returns the chosen profile names, or an error if cancelled / empty list.
+	PickMany(profiles []profiles.Profile) ([]string, error)
+}
+
 // HuhSelector is the production implementation backed by github.com/charmbracelet/huh.
 type HuhSelector struct{}
 
-// Compile-time assertion that HuhSelector satisfies the Selector interface.
-var _ Selector = HuhSelector{}
+// Compile-time assertions that HuhSelector satisfies both selector interfaces.
+var (
+	_ Selector      = HuhSelector{}
+	_ MultiSelector = HuhSelector{}
+)
 
 // Pick presents a single-select prompt listing the supplied profiles and returns
 // the chosen profile name. Returns an error if the list is empty or the user
@@ -29,16 +117,10 @@ func (HuhSelector) Pick(p []profiles.Profile) (string, error) {
 		return "", errors.New("no SSO profiles available to select")
 	}
 
-	opts := make([]huh.Option[string], 0, len(p))
-	for _, prof := range p {
-		label := fmt.Sprintf("%-30s  %-12s  %s", prof.Name, prof.AccountID, prof.RoleName)
-		opts = append(opts, huh.NewOption(label, prof.Name))
-	}
-
 	var choice string
 	err := huh.NewSelect[string]().
 		Title("Select an AWS SSO profile").
-		Options(opts...).
+		Options(profileOptions(p)...).
 		Value(&choice).
 		Run()
 	if err != nil {
@@ -50,3 +132,46 @@ func (HuhSelector) Pick(p []profiles.Profile) (string, error) {
 
 	return choice, nil
 }
+
+// PickMany presents a filterable multi-select prompt listing the supplied
+// profiles and returns the chosen profile names. Typing "/" filters on the
+// profile name, account ID or role name. Returns an error if the list is
+// empty, nothing is selected or the user cancels the prompt.
+func (HuhSelector) PickMany(p []profiles.Profile) ([]string, error) {
+	if len(p) == 0 {
+		return nil, errors.New("no SSO profiles available to select")
+	}
+
+	var choices []string
+	err := huh.NewMultiSelect[string]().
+		Title("Select AWS SSO profiles (space to toggle, / to filter)").
+		Options(profileOptions(p)...).
+		Filterable(true).
+		Validate(func(selected []string) error {
+			if len(selected) == 0 {
+				return errors.New("select at least one profile")
+			}
+			return nil
+		}).
+		Value(&choices).
+		Run()
+	if err != nil {
+		if errors.Is(err, huh.ErrUserAborted) {
+			return nil, errors.New("profile selection cancelled")
+		}
+		return nil, fmt.Errorf("running profile selector: %w", err)
+	}
+
+	return choices, nil
+}
+
+// profileOptions renders one option per profile. The label carries the
+// account ID and role name so that filtering matches on them as well.
+func profileOptions(p []profiles.Profile) []huh.Option[string] {
+	opts := make([]huh.Option[string], 0, len(p))
+	for _, prof := range p {
+		label := fmt.Sprintf("%-30s  %-12s  %s", prof.Name, prof.AccountID, prof.RoleName)
+		opts = append(opts, huh.NewOption(label, prof.Name))
+	}
+	return opts
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/witnsby/aws-sso-login/src/internal/profiles"
@@ -12,8 +13,9 @@ import (
 // without spawning the interactive UI). Per Q5 the full huh UI is not unit-tested;
 // this fake covers interface wiring only.
 type fakeSelector struct {
-	choice string
-	err    error
+	choice  string
+	choices []string
+	err     error
 }
 
 func (f fakeSelector) Pick(_ []profiles.Profile) (string, error) {
@@ -23,6 +25,13 @@ func (f fakeSelector) Pick(_ []profiles.Profile) (string, error) {
 	return f.choice, nil
 }
 
+func (f fakeSelector) PickMany(_ []profiles.Profile) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.choices, nil
+}
+
 // TestSelectorContract verifies the Selector interface contract:
 //   - HuhSelector satisfies Selector (compile-time and runtime).
 //   - HuhSelector.Pick returns an error when given an empty profile list.
@@ -46,6 +55,35 @@ func TestSelectorContract(t *testing.T) {
 		}
 	})
 
+	t.Run("HuhSelector satisfies MultiSelector", func(t *testing.T) {
+		t.Parallel()
+		var s MultiSelector = HuhSelector{}
+		if s == nil {
+			t.Fatal("HuhSelector{} should satisfy MultiSelector")
+		}
+	})
+
+	t.Run("HuhSelector.PickMany rejects empty profile list", func(t *testing.T) {
+		t.Parallel()
+		_, err := HuhSelector{}.PickMany(nil)
+		if err == nil {
+			t.Fatal("expected error for empty profile list, got nil")
+		}
+	})
+
+	t.Run("profile options carry account ID and role for filtering", func(t *testing.T) {
+		t.Parallel()
+		opts := profileOptions([]profiles.Profile{{Name: "dev", AccountID: "123456789012", RoleName: "ReadOnly"}})
+		if len(opts) != 1 || opts[0].Value != "dev" {
+			t.Fatalf("unexpected options: %#v", opts)
+		}
+		for _, want := range []string{"dev", "123456789012", "ReadOnly"} {
+			if !strings.Contains(opts[0].Key, want) {
+				t.Fatalf("option label %q missing %q", opts[0].Key, want)
+			}
+		}
+	})
+
 	t.Run("fakeSelector returns preset choice", func(t *testing.T) {
 		t.Parallel()
 		var s Selector = fakeSelector{choice: "my-profile"}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/credserver"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+)
+
+const (
+	// serveTokenEnv supplies a fixed authorization token for the container endpoint.
+	serveTokenEnv = "AWS_SSO_LOGIN_SERVE_TOKEN"
+	// serveMinRefreshWindow is the least time before expiry at which served
+	// credentials are refreshed, so clients never receive nearly expired ones.
+	serveMinRefreshWindow = 5 * time.Minute
+	// serveShutdownTimeout bounds how long in-flight requests may take on shutdown.
+	serveShutdownTimeout = 5 * time.Second
+)
+
+// serveOptions configures the credential server.
+type serveOptions struct {
+	// listen is the loopback address of the container credentials endpoint.
+	listen string
+	// imdsListen is the loopback address of the IMDSv2 endpoint; empty disables it.
+	imdsListen string
+}
+
+// servedCredentials hands out the profile's credentials to the server and
+// refreshes them when they come within the refresh window of expiring.
+type servedCredentials struct {
+	mu      sync.Mutex
+	manager *awsCredentialsManager
+	window  time.Duration
+}
+
+// get implements credserver.Provider. It never starts an SSO login: the
+// device flow waits for the user, and every client request would block
+// behind it. When the SSO session has expired the request fails (the server
+// answers 500) until the user logs in again.
+func (s *servedCredentials) get() (*model.RoleCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.manager.roleCred == nil || expiresWithin(s.manager.roleCred.Expiration, s.window) {
+		if err := s.manager.refreshRoleCredentials(); err != nil {
+			if errors.Is(err, errSSOLoginRequired) {
+				return nil, fmt.Errorf("%w; log in again, e.g. with `aws-sso-login exec --profile %s -- true`, "+
+					"and serve picks up the new session", err, s.manager.profileName)
+			}
+			return nil, err
+		}
+	}
+	rc := *s.manager.roleCred
+	rc.Expiration = credentialExpiration(rc.Expiration)
+	return &rc, nil
+}
+
+// serve exposes the profile's credentials on a container credentials
+// endpoint (AWS_CONTAINER_CREDENTIALS_FULL_URI) and optionally an IMDSv2
+// endpoint, both on loopback addresses, until interrupted. Credentials are
+// fetched on demand and never written to disk by the server itself. The
+// environment variables clients need are printed on startup.
+func serve(profileName string, opts serveOptions) error {
+	profile, err := retrieveProfile(profileName)
+	if err != nil {
+		return err
+	}
+	window, err := resolveRefreshWindow(profile)
+	if err != nil {
+		return err
+	}
+	if window < serveMinRefreshWindow {
+		window = serveMinRefreshWindow
+	}
+
+	// The credential source refreshes as early as the server does.
+	manager := &awsCredentialsManager{profileName: profileName, minRefreshWindow: window}
+	provider := &servedCredentials{manager: manager, window: window}
+	// Log in now if needed, while the user is at the terminal.
+	if err := manager.retrieveAndSetProfile(); err != nil {
+		return err
+	}
+
+	token := os.Getenv(serveTokenEnv)
+	if token == "" {
+		if token, err = credserver.NewToken(); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ecsListener, err := credserver.ListenLoopback(opts.listen)
+	if err != nil {
+		return err
+	}
+	servers := []*http.Server{{Handler: credserver.ECSHandler(token, provider.get)}}
+	listeners := []net.Listener{ecsListener}
+	vars := []envVar{
+		{"AWS_CONTAINER_CREDENTIALS_FULL_URI", fmt.Sprintf("http://%s/", ecsListener.Addr())},
+		{"AWS_CONTAINER_AUTHORIZATION_TOKEN", token},
+	}
+
+	if opts.imdsListen != "" {
+		imdsListener, err := credserver.ListenLoopback(opts.imdsListen)
+		if err != nil {
+			_ = ecsListener.Close()
+			return err
+		}
+		_, roleName := profileAccountAndRole(provider.manager.profile)
+		region := provider.manager.profile.Key("region").String()
+		servers = append(servers, &http.Server{Handler: credserver.IMDSHandler(roleName, region, provider.get)})
+		listeners = append(listeners, imdsListener)
+		vars = append(vars, envVar{"AWS_EC2_METADATA_SERVICE_ENDPOINT", fmt.Sprintf("http://%s/", imdsListener.Addr())})
+	}
+
+	out, err := exportFormats[detectExportFormat()](vars)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(exportOutput, out); err != nil {
+		return err
+	}
+
+	errs := make(chan error, len(servers))
+	for i, srv := range servers {
+		srv.ReadHeaderTimeout = 10 * time.Second
+		go func(srv *http.Server, ln net.Listener) {
+			errs <- srv.Serve(ln)
+		}(srv, listeners[i])
+	}
+	logrus.Infof("Serving credentials for profile %s on %s; press Ctrl+C to stop", profileName, ecsListener.Addr())
+
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		_ = srv.Shutdown(shutdownCtx)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("credential server failed: %w", err)
+	}
+	return nil
+}
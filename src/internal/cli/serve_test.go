@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ini/ini"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+)
+
+// countingSource hands out credentials expiring after ttl and counts calls.
+type countingSource struct {
+	ttl   time.Duration
+	calls int
+}
+
+func (s *countingSource) Retrieve(string, *ini.Section) (*model.RoleCredential, error) {
+	s.calls++
+	return &model.RoleCredential{
+		AccessKeyId:     "ASIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		Expiration:      time.Now().Add(s.ttl).UTC().Format("2006-01-02T15:04:05Z"),
+	}, nil
+}
+
+func TestServedCredentials_RefreshesBeforeExpiry(t *testing.T) {
+	writeAwsConfig(t, `[profile dev-account]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+`)
+	isolateAwsPaths(t)
+
+	src := &countingSource{ttl: time.Hour}
+	origFlag := credentialSourceFlag
+	t.Cleanup(func() {
+		credentialSourceFlag = origFlag
+		delete(credentialSources, "counting")
+	})
+	credentialSources["counting"] = src
+	credentialSourceFlag = "counting"
+
+	provider := &servedCredentials{manager: &awsCredentialsManager{profileName: "dev-account"}, window: serveMinRefreshWindow}
+	for i := 0; i < 3; i++ {
+		if _, err := provider.get(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if src.calls != 1 {
+		t.Fatalf("expected fresh credentials to be reused, got %d fetches", src.calls)
+	}
+
+	// Credentials inside the refresh window are fetched again on the next request.
+	src.ttl = 2 * time.Minute
+	provider.manager.roleCred.Expiration = time.Now().Add(time.Minute).UTC().Format("2006-01-02T15:04:05Z")
+	rc, err := provider.get()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if src.calls != 2 {
+		t.Fatalf("expected a refresh, got %d fetches", src.calls)
+	}
+	if _, err := time.Parse(time.RFC3339, rc.Expiration); err != nil {
+		t.Fatalf("expected an RFC 3339 expiration, got %q", rc.Expiration)
+	}
+}
+
+// TestServedCredentials_DoesNotLogIn verifies that a request never starts the
+// interactive SSO login; an expired session fails the request instead.
+func TestServedCredentials_DoesNotLogIn(t *testing.T) {
+	writeAwsConfig(t, `[profile dev-account]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+`)
+	isolateAwsPaths(t)
+	useStaticSource(t, StaticSource{Err: fmt.Errorf("%w for profile %q", errSSOLoginRequired, "dev-account")})
+	origLogin := runSSOLogin
+	t.Cleanup(func() { runSSOLogin = origLogin })
+	runSSOLogin = func(*ini.Section) error {
+		t.Error("a served request must not start an SSO login")
+		return nil
+	}
+
+	provider := &servedCredentials{manager: &awsCredentialsManager{profileName: "dev-account"}, window: serveMinRefreshWindow}
+	_, err := provider.get()
+	if !errors.Is(err, errSSOLoginRequired) {
+		t.Fatalf("expected errSSOLoginRequired, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "log in again") {
+		t.Errorf("expected a hint to log in again, got %v", err)
+	}
+}
+
+// TestCliCacheSource_RetrieveWithin verifies that a caller's minimum window
+// triggers an early refresh without touching the --refresh-window flag.
+func TestCliCacheSource_RetrieveWithin(t *testing.T) {
+	isolateAwsPaths(t)
+	cfg, err := ini.Load([]byte(`[profile p]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Admin
+`))
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	profile := cfg.Section("profile p")
+	origFlag := refreshWindowFlag
+	t.Cleanup(func() { refreshWindowFlag = origFlag })
+	refreshWindowFlag = ""
+
+	write := func(ttl time.Duration) {
+		t.Helper()
+		if err := writeCachedRoleCredentials(profile, &model.RoleCredential{
+			AccessKeyId:     "ASIAEXAMPLE",
+			SecretAccessKey: "secret",
+			SessionToken:    "token",
+			Expiration:      time.Now().Add(ttl).UTC().Format("2006-01-02T15:04:05Z"),
+		}); err != nil {
+			t.Fatalf("write cache: %v", err)
+		}
+	}
+	write(3 * time.Minute)
+	refreshes := 0
+	source := &cliCacheSource{refresh: func(string, *ini.Section) error {
+		refreshes++
+		write(time.Hour)
+		return nil
+	}}
+
+	if _, err := source.Retrieve("p", profile); err != nil || refreshes != 0 {
+		t.Fatalf("expected the cached credentials without a refresh, got %d refreshes, err %v", refreshes, err)
+	}
+	if _, err := source.RetrieveWithin("p", profile, serveMinRefreshWindow); err != nil || refreshes != 1 {
+		t.Fatalf("expected one refresh inside the minimum window, got %d, err %v", refreshes, err)
+	}
+	if refreshWindowFlag != "" {
+		t.Errorf("--refresh-window was changed to %q", refreshWindowFlag)
+	}
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-ini/ini"
+	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+)
+
+const (
+	// sessionDurationEnv sets the console session length when --session-duration is not set.
+	sessionDurationEnv = "AWS_SSO_LOGIN_SESSION_DURATION"
+	// sessionDurationKey sets the console session length per profile in ~/.aws/config.
+	sessionDurationKey = "sso_login_session_duration"
+)
+
+// resolveSessionDuration returns the console session length requested with
+// --session-duration, AWS_SSO_LOGIN_SESSION_DURATION or the profile's
+// sso_login_session_duration key (in that order), defaulting to the 12h
+// federation maximum.
+//
+// Requested values outside the federation limits (15m–12h) are an error. The
+// result is clamped to the remaining lifetime of the role credentials, which
+// the federation endpoint would otherwise reject; credentials with less than
+// 15 minutes left cannot start a console session at all.
+func resolveSessionDuration(flagValue string, profile *ini.Section, expiration string, now time.Time) (time.Duration, error) {
+	raw, origin := flagValue, "--session-duration"
+	if raw == "" {
+		raw, origin = os.Getenv(sessionDurationEnv), sessionDurationEnv
+	}
+	if raw == "" && profile != nil {
+		raw, origin = profile.Key(sessionDurationKey).String(), sessionDurationKey
+	}
+
+	duration := helper.MaxSessionDuration
+	if raw != "" {
+		d, err := parseDurationOrSeconds(raw)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q: %w", origin, raw, err)
+		}
+		if d < helper.MinSessionDuration || d > helper.MaxSessionDuration {
+			return 0, fmt.Errorf("%s %q is out of range: console sessions must last between %s and %s",
+				origin, raw, helper.MinSessionDuration, helper.MaxSessionDuration)
+		}
+		duration = d
+	}
+
+	expiresAt, err := parseExpirationTime(expiration)
+	if err != nil {
+		return duration, nil
+	}
+	remaining := expiresAt.Sub(now).Truncate(time.Second)
+	if remaining >= duration {
+		return duration, nil
+	}
+	if remaining < helper.MinSessionDuration {
+		return 0, fmt.Errorf("role credentials expire in %s, less than the %s minimum console session; "+
+			"refresh them first, e.g. with --refresh-window %s", remaining.Round(time.Second), helper.MinSessionDuration, helper.MinSessionDuration)
+	}
+	if raw != "" {
+		logrus.Warnf("Requested console session of %s exceeds the remaining credential lifetime; using %s", duration, remaining)
+	}
+	return remaining, nil
+}
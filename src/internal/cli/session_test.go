@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ini/ini"
+)
+
+func TestResolveSessionDuration(t *testing.T) {
+	cfg, err := ini.Load([]byte(`[profile short]
+sso_login_session_duration = 1h
+
+[profile plain]
+`))
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiresIn := func(d time.Duration) string { return now.Add(d).Format("2006-01-02T15:04:05Z") }
+
+	cases := []struct {
+		name      string
+		flag      string
+		env       string
+		profile   string
+		remaining time.Duration
+		want      time.Duration
+		wantErr   string
+	}{
+		{name: "default is the federation maximum", profile: "plain", remaining: 24 * time.Hour, want: 12 * time.Hour},
+		{name: "profile key", profile: "short", remaining: 24 * time.Hour, want: time.Hour},
+		{name: "env beats profile", env: "45m", profile: "short", remaining: 24 * time.Hour, want: 45 * time.Minute},
+		{name: "flag beats env", flag: "2h", env: "45m", profile: "short", remaining: 24 * time.Hour, want: 2 * time.Hour},
+		{name: "seconds", flag: "3600", profile: "plain", remaining: 24 * time.Hour, want: time.Hour},
+		{name: "clamped to credential lifetime", flag: "8h", profile: "plain", remaining: 3 * time.Hour, want: 3 * time.Hour},
+		{name: "default clamped to credential lifetime", profile: "plain", remaining: 90 * time.Minute, want: 90 * time.Minute},
+		{name: "below minimum", flag: "5m", profile: "plain", remaining: 24 * time.Hour, wantErr: "out of range"},
+		{name: "above maximum", flag: "13h", profile: "plain", remaining: 24 * time.Hour, wantErr: "out of range"},
+		{name: "unparseable", flag: "two hours", profile: "plain", remaining: 24 * time.Hour, wantErr: "invalid --session-duration"},
+		{name: "credentials about to expire", profile: "plain", remaining: 10 * time.Minute, wantErr: "less than the 15m0s minimum"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(sessionDurationEnv, tc.env)
+			got, err := resolveSessionDuration(tc.flag, cfg.Section("profile "+tc.profile), expiresIn(tc.remaining), now)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v (%s)", tc.wantErr, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-ini/ini"
+	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
+)
+
+// CredentialSource produces role credentials for an SSO profile. It is the
+// seam between the commands (console, export, import, process) and the place
+// the credentials actually come from.
+type CredentialSource interface {
+	// Retrieve returns unexpired role credentials for the profile.
+	Retrieve(profileName string, profile *ini.Section) (*model.RoleCredential, error)
+}
+
+const (
+	// credentialSourceEnv selects the credential source when --credential-source is not set.
+	credentialSourceEnv = "AWS_SSO_LOGIN_CREDENTIAL_SOURCE"
+	// credentialSourceKey selects the credential source per profile in ~/.aws/config.
+	credentialSourceKey = "sso_login_credential_source"
+	// defaultCredentialSource is used when nothing else is configured.
+	defaultCredentialSource = "native"
+)
+
+// Profile keys holding the credentials of the static source. Only the access
+// key ID is required; the expiration defaults to an hour from now.
+const (
+	staticAccessKeyIDKey     = "sso_login_static_access_key_id"
+	staticSecretAccessKeyKey = "sso_login_static_secret_access_key"
+	staticSessionTokenKey    = "sso_login_static_session_token"
+	staticExpirationKey      = "sso_login_static_expiration"
+)
+
+// earlyRefresher is implemented by sources that can refresh credentials
+// ahead of a caller's minimum refresh window, on top of the configured one.
+type earlyRefresher interface {
+	RetrieveWithin(profileName string, profile *ini.Section, minWindow time.Duration) (*model.RoleCredential, error)
+}
+
+// credentialSourceFlag holds the value of the global --credential-source flag.
+var credentialSourceFlag string
+
+// credentialSources maps the names accepted by --credential-source to their
+// implementations.
+var credentialSources = map[string]CredentialSource{}
+
+// The built-in sources are registered in init because the native refresh of a
+// chained profile resolves its source_profile through credentialSources again.
+func init() {
+	credentialSources["native"] = &cliCacheSource{refresh: updateCachedRoleCredentials}
+	credentialSources["awscli"] = &cliCacheSource{refresh: refreshWithAWSCLI}
+	credentialSources["static"] = StaticSource{}
+}
+
+// resolveCredentialSource picks the CredentialSource for a profile. The
+// --credential-source flag wins over AWS_SSO_LOGIN_CREDENTIAL_SOURCE, which
+// wins over the profile's sso_login_credential_source key; "native" is the default.
+func resolveCredentialSource(profile *ini.Section) (CredentialSource, error) {
+	name := credentialSourceFlag
+	if name == "" {
+		name = os.Getenv(credentialSourceEnv)
+	}
+	if name == "" && profile != nil {
+		name = profile.Key(credentialSourceKey).String()
+	}
+	if name == "" {
+		name = defaultCredentialSource
+	}
+
+	source, ok := credentialSources[name]
+	if !ok {
+		names := make([]string, 0, len(credentialSources))
+		for n := range credentialSources {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown credential source %q (valid: %s)", name, strings.Join(names, ", "))
+	}
+	return source, nil
+}
+
+// cliCacheSource serves credentials from ~/.aws/cli/cache and calls refresh to
+// repopulate the cache entry when it is missing or expired.
+type cliCacheSource struct {
+	refresh func(profileName string, profile *ini.Section) error
+}
+
+// Retrieve implements CredentialSource. Cached credentials that expire within
+// the profile's refresh window are refreshed proactively.
+func (s *cliCacheSource) Retrieve(profileName string, profile *ini.Section) (*model.RoleCredential, error) {
+	return s.RetrieveWithin(profileName, profile, 0)
+}
+
+// RetrieveWithin implements earlyRefresher: the refresh window is the
+// profile's, raised to minWindow if that is longer.
+func (s *cliCacheSource) RetrieveWithin(profileName string, profile *ini.Section, minWindow time.Duration) (*model.RoleCredential, error) {
+	window, err := resolveRefreshWindow(profile)
+	if err != nil {
+		return nil, err
+	}
+	if window < minWindow {
+		window = minWindow
+	}
+
+	// Try reading from the CLI cache
+	roleCred, err := getCachedRoleCredentials(profile)
+	if err == nil && roleCred != nil {
+		// If credentials outlive the refresh window, return them
+		if !expiresWithin(roleCred.Expiration, window) {
+			return roleCred, nil
+		}
+		// Otherwise, we'll refresh
+	}
+
+	// If we couldn't read them, or they're (nearly) expired, attempt to refresh
+	if err = s.refresh(profileName, profile); err != nil {
+		return nil, err
+	}
+
+	// Then try again
+	roleCred, err = getCachedRoleCredentials(profile)
+	if err != nil {
+		return nil, err
+	}
+	if roleCred == nil {
+		return nil, fmt.Errorf("could not retrieve credentials for '%s'", profileName)
+	}
+	// Final check
+	if isExpired(roleCred.Expiration) {
+		return nil, fmt.Errorf("credentials for '%s' are expired", profileName)
+	}
+	if expiresWithin(roleCred.Expiration, window) {
+		logrus.Warnf("Refreshed credentials for '%s' still expire within the %s refresh window", profileName, window)
+	}
+	return roleCred, nil
+}
+
+// refreshWithAWSCLI calls `aws sts get-caller-identity --profile=XYZ`, which
+// makes the AWS CLI refresh ~/.aws/cli/cache through its own SSO logic.
+//
+// If the AWS CLI exits with a "no access" condition (ForbiddenException /
+// AccessDeniedException returned by the SSO GetRoleCredentials API), the
+// returned error wraps errSSORoleNoAccess; any other failure wraps
+// errSSOLoginRequired.
+func refreshWithAWSCLI(profileName string, _ *ini.Section) error {
+	cmd := execCommand("aws", "sts", "get-caller-identity",
+		"--query", "Arn", "--output", "text", "--profile", profileName)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		stderrStr := strings.TrimSpace(stderr.String())
+		if stderrStr != "" {
+			logrus.Debug(stderrStr)
+		}
+		if isSSORoleNoAccessStderr(stderrStr) {
+			return fmt.Errorf("%w for profile %q", errSSORoleNoAccess, profileName)
+		}
+		return fmt.Errorf("%w for profile %q", errSSOLoginRequired, profileName)
+	}
+	logrus.Infof("Updated credentials for: %s", strings.TrimSpace(string(output)))
+	return nil
+}
+
+// isSSORoleNoAccessStderr returns true when the AWS CLI stderr indicates the
+// authenticated user has no access to the configured SSO role. These cases
+// cannot be resolved by logging in again.
+func isSSORoleNoAccessStderr(stderr string) bool {
+	if stderr == "" {
+		return false
+	}
+	lower := strings.ToLower(stderr)
+	return strings.Contains(lower, "forbiddenexception") ||
+		strings.Contains(lower, "accessdeniedexception") ||
+		strings.Contains(lower, "no access")
+}
+
+// StaticSource always returns the same credentials (or error). It lets
+// commands be exercised without an SSO login or the AWS CLI. Registered as
+// "static", it has neither and reads the credentials from the profile's
+// sso_login_static_* keys instead.
+type StaticSource struct {
+	Credential *model.RoleCredential
+	Err        error
+}
+
+// Retrieve implements CredentialSource.
+func (s StaticSource) Retrieve(profileName string, profile *ini.Section) (*model.RoleCredential, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	if s.Credential != nil {
+		return s.Credential, nil
+	}
+	if profile == nil || profile.Key(staticAccessKeyIDKey).String() == "" {
+		return nil, fmt.Errorf("could not retrieve credentials for '%s': %s is not set", profileName, staticAccessKeyIDKey)
+	}
+
+	expiration := profile.Key(staticExpirationKey).String()
+	if expiration == "" {
+		expiration = time.Now().Add(time.Hour).UTC().Format(sso.TimeFormat)
+	} else if _, err := parseExpirationTime(expiration); err != nil {
+		return nil, fmt.Errorf("invalid %s %q for profile '%s': %w", staticExpirationKey, expiration, profileName, err)
+	}
+	return &model.RoleCredential{
+		AccessKeyId:     profile.Key(staticAccessKeyIDKey).String(),
+		SecretAccessKey: profile.Key(staticSecretAccessKeyKey).String(),
+		SessionToken:    profile.Key(staticSessionTokenKey).String(),
+		Expiration:      expiration,
+	}, nil
+}
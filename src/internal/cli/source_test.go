@@ -0,0 +1,301 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ini/ini"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
+)
+
+// useStaticSource registers src as the "static" credential source and selects
+// it via the --credential-source flag for the duration of the test.
+func useStaticSource(t *testing.T, src StaticSource) {
+	t.Helper()
+	origFlag, origSource := credentialSourceFlag, credentialSources["static"]
+	t.Cleanup(func() {
+		credentialSourceFlag = origFlag
+		credentialSources["static"] = origSource
+	})
+	credentialSources["static"] = src
+	credentialSourceFlag = "static"
+}
+
+func TestIsSSORoleNoAccessStderr(t *testing.T) {
+	cases := []struct {
+		name   string
+		stderr string
+		want   bool
+	}{
+		{
+			name:   "empty",
+			stderr: "",
+			want:   false,
+		},
+		{
+			name:   "forbidden exception from GetRoleCredentials",
+			stderr: "An error occurred (ForbiddenException) when calling the GetRoleCredentials operation: No access",
+			want:   true,
+		},
+		{
+			name:   "access denied exception",
+			stderr: "An error occurred (AccessDeniedException) when calling the AssumeRoleWithSAML operation",
+			want:   true,
+		},
+		{
+			name:   "lowercase no access phrase",
+			stderr: "request failed: no access for the requested resource",
+			want:   true,
+		},
+		{
+			name:   "invalid grant is not no-access",
+			stderr: "An error occurred (InvalidGrantException) when calling the CreateToken operation",
+			want:   false,
+		},
+		{
+			name:   "unrelated network error",
+			stderr: "Could not connect to the endpoint URL",
+			want:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isSSORoleNoAccessStderr(tc.stderr); got != tc.want {
+				t.Fatalf("isSSORoleNoAccessStderr(%q) = %v, want %v", tc.stderr, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveCredentialSource(t *testing.T) {
+	cfg, err := ini.Load([]byte(`[profile p]
+sso_login_credential_source = awscli
+`))
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	profile := cfg.Section("profile p")
+	origFlag := credentialSourceFlag
+	t.Cleanup(func() { credentialSourceFlag = origFlag })
+
+	cases := []struct {
+		name    string
+		flag    string
+		env     string
+		profile *ini.Section
+		want    CredentialSource
+		wantErr string
+	}{
+		{name: "default is native", want: credentialSources["native"]},
+		{name: "profile key", profile: profile, want: credentialSources["awscli"]},
+		{name: "env beats profile", env: "native", profile: profile, want: credentialSources["native"]},
+		{name: "flag beats env", flag: "awscli", env: "native", want: credentialSources["awscli"]},
+		{name: "static", flag: "static", want: credentialSources["static"]},
+		{name: "unknown source", flag: "bogus", wantErr: `unknown credential source "bogus" (valid: awscli, native, static)`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			credentialSourceFlag = tc.flag
+			t.Setenv(credentialSourceEnv, tc.env)
+
+			got, err := resolveCredentialSource(tc.profile)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("resolveCredentialSource() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestRetrieveAndSetProfile_StaticSource exercises the shared command flow
+// end to end without an SSO login or the AWS CLI.
+func TestRetrieveAndSetProfile_StaticSource(t *testing.T) {
+	writeAwsConfig(t, `[profile dev-account]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+`)
+	want := &model.RoleCredential{AccessKeyId: "AKIAEXAMPLE", Expiration: "2099-01-01T00:00:00Z"}
+	useStaticSource(t, StaticSource{Credential: want})
+
+	manager := awsCredentialsManager{profileName: "dev-account"}
+	if err := manager.retrieveAndSetProfile(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if manager.roleCred != want {
+		t.Fatalf("roleCred = %#v, want %#v", manager.roleCred, want)
+	}
+}
+
+// TestStaticSource_FromProfile selects the registered static source through
+// the profile and exports the credentials its sso_login_static_* keys hold.
+func TestStaticSource_FromProfile(t *testing.T) {
+	writeAwsConfig(t, `[profile dev-account]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+sso_login_credential_source = static
+sso_login_static_access_key_id = AKIAEXAMPLE
+sso_login_static_secret_access_key = static-secret
+sso_login_static_expiration = 2099-01-01T00:00:00Z
+
+[profile incomplete]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+sso_login_credential_source = static
+`)
+	t.Setenv(credentialSourceEnv, "")
+	origFlag := credentialSourceFlag
+	credentialSourceFlag = ""
+	t.Cleanup(func() { credentialSourceFlag = origFlag })
+	var out bytes.Buffer
+	origOut := exportOutput
+	exportOutput = &out
+	t.Cleanup(func() { exportOutput = origOut })
+
+	if err := exportCredsToOutput("dev-account", "sh"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"AWS_ACCESS_KEY_ID='AKIAEXAMPLE'", "AWS_SECRET_ACCESS_KEY='static-secret'"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("export output missing %s:\n%s", want, out.String())
+		}
+	}
+
+	profile, err := retrieveProfile("incomplete")
+	if err != nil {
+		t.Fatalf("retrieveProfile: %v", err)
+	}
+	if _, err := getRoleCredentials("incomplete", profile); err == nil || !strings.Contains(err.Error(), staticAccessKeyIDKey) {
+		t.Errorf("expected an error naming %s, got %v", staticAccessKeyIDKey, err)
+	}
+}
+
+// TestRetrieveAndSetProfile_NoAccessSkipsLogin verifies that errSSORoleNoAccess
+// from the credential source is returned without attempting a login.
+func TestRetrieveAndSetProfile_NoAccessSkipsLogin(t *testing.T) {
+	writeAwsConfig(t, `[profile dev-account]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = AdministratorAccess
+`)
+	useStaticSource(t, StaticSource{Err: errSSORoleNoAccess})
+
+	manager := awsCredentialsManager{profileName: "dev-account"}
+	err := manager.retrieveAndSetProfile()
+	if !errors.Is(err, errSSORoleNoAccess) {
+		t.Fatalf("expected errSSORoleNoAccess, got %v", err)
+	}
+	if strings.Contains(err.Error(), "SSO login") {
+		t.Fatalf("expected no login attempt, got %v", err)
+	}
+}
+
+// fakePortal serves GetRoleCredentials for the native source and counts calls.
+func fakePortal(t *testing.T, expiresIn time.Duration) *int {
+	t.Helper()
+	calls := 0
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		if r.Header.Get("x-amz-sso_bearer_token") != "access-token" {
+			w.Header().Set("x-amzn-ErrorType", "UnauthorizedException")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"roleCredentials": map[string]any{
+				"accessKeyId":     "ASIAFRESH",
+				"secretAccessKey": "secret",
+				"sessionToken":    "session",
+				"expiration":      time.Now().Add(expiresIn).UnixMilli(),
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	orig := newSSOClient
+	t.Cleanup(func() { newSSOClient = orig })
+	newSSOClient = func(string) *sso.Client {
+		return &sso.Client{OIDCEndpoint: srv.URL, PortalEndpoint: srv.URL, HTTPClient: srv.Client()}
+	}
+	return &calls
+}
+
+func TestNativeSource_RefreshesIntoCLICache(t *testing.T) {
+	dir := isolateAwsPaths(t)
+	calls := fakePortal(t, time.Hour)
+	cfg, err := ini.Load([]byte(`[profile p]
+sso_session = corp
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = Admin
+`))
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	profile := cfg.Section("profile p")
+	source := credentialSources["native"]
+
+	// Without a cached SSO token a login is required.
+	if _, err := source.Retrieve("p", profile); !errors.Is(err, errSSOLoginRequired) {
+		t.Fatalf("expected errSSOLoginRequired, got %v", err)
+	}
+
+	token := &sso.CachedToken{AccessToken: "access-token", ExpiresAt: time.Now().Add(time.Hour).UTC().Format(sso.TimeFormat)}
+	if err := sso.SaveToken(filepath.Join(dir, "sso", "cache"), sso.TokenCacheKey("corp", ""), token); err != nil {
+		t.Fatalf("save token: %v", err)
+	}
+
+	cred, err := source.Retrieve("p", profile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cred.AccessKeyId != "ASIAFRESH" || *calls != 1 {
+		t.Fatalf("got %q after %d portal calls, want ASIAFRESH after 1", cred.AccessKeyId, *calls)
+	}
+
+	// The second call is served from ~/.aws/cli/cache.
+	if _, err := source.Retrieve("p", profile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *calls != 1 {
+		t.Fatalf("expected cached credentials to be reused, got %d portal calls", *calls)
+	}
+
+	// A refresh window longer than the remaining lifetime forces a refresh.
+	t.Setenv(refreshWindowEnv, "2h")
+	if _, err := source.Retrieve("p", profile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *calls != 2 {
+		t.Fatalf("expected a proactive refresh, got %d portal calls", *calls)
+	}
+}
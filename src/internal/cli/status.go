@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+	"github.com/witnsby/aws-sso-login/src/internal/profiles"
+	"github.com/witnsby/aws-sso-login/src/internal/redact"
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
+)
+
+// statusOutput receives the status report.
+var statusOutput io.Writer = os.Stdout
+
+// profileStatus describes the cached credential and token state of a profile.
+type profileStatus struct {
+	Profile               string `json:"profile"`
+	CredentialsCached     bool   `json:"credentialsCached"`
+	CredentialsExpiration string `json:"credentialsExpiration,omitempty"`
+	CredentialsRemaining  int64  `json:"credentialsRemainingSeconds"`
+	CacheFile             string `json:"cacheFile,omitempty"`
+	SSOTokenValid         bool   `json:"ssoTokenValid"`
+	SSOTokenExpiration    string `json:"ssoTokenExpiration,omitempty"`
+	Error                 string `json:"error,omitempty"`
+}
+
+// status reports the cached state of profileName, or of every SSO profile in
+// the config when profileName is empty, as a table or as JSON. It only reads
+// the caches; no login or credential refresh is performed.
+func status(profileName, format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (valid: json, table)", format)
+	}
+
+	names := []string{profileName}
+	if profileName == "" {
+		configPath, err := helper.GetAwsConfigPath()
+		if err != nil {
+			return fmt.Errorf("could not determine AWS config path: %w", err)
+		}
+		ssoProfiles, err := profiles.ListSSOProfiles(configPath)
+		if err != nil {
+			return fmt.Errorf("could not list SSO profiles: %w", err)
+		}
+		names = names[:0]
+		for _, p := range ssoProfiles {
+			names = append(names, p.Name)
+		}
+	}
+
+	now := time.Now()
+	statuses := make([]profileStatus, 0, len(names))
+	for _, name := range names {
+		statuses = append(statuses, profileStatusFor(name, now))
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(statusOutput)
+		enc.SetIndent("", "  ")
+		return enc.Encode(statuses)
+	}
+	printStatusTable(statusOutput, statuses)
+	return nil
+}
+
+// profileStatusFor inspects the CLI credential cache and SSO token cache for a profile.
+func profileStatusFor(profileName string, now time.Time) profileStatus {
+	st := profileStatus{Profile: profileName}
+	profile, err := retrieveProfile(profileName)
+	if err != nil {
+		st.Error = redact.String(err.Error())
+		return st
+	}
+
+	if st.CacheFile, err = buildCacheFilePath(profile); err != nil {
+		st.Error = redact.String(err.Error())
+		return st
+	}
+	if roleCred, err := getCachedRoleCredentials(profile); err == nil && roleCred != nil {
+		st.CredentialsCached = true
+		st.CredentialsExpiration = credentialExpiration(roleCred.Expiration)
+		if expiresAt, err := parseExpirationTime(roleCred.Expiration); err == nil && expiresAt.After(now) {
+			st.CredentialsRemaining = int64(expiresAt.Sub(now).Seconds())
+		}
+	}
+
+	cacheDir, err := helper.GetAwsSSOCachePath()
+	if err != nil {
+		st.Error = redact.String(err.Error())
+		return st
+	}
+	key := sso.TokenCacheKey(profile.Key("sso_session").String(), profile.Key("sso_start_url").String())
+	if token, err := sso.LoadToken(cacheDir, key); err == nil {
+		st.SSOTokenValid = token.Valid(now)
+		st.SSOTokenExpiration = token.ExpiresAt
+	}
+	return st
+}
+
+// printStatusTable writes one line per profile status.
+func printStatusTable(w io.Writer, statuses []profileStatus) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROFILE\tCREDENTIALS\tREMAINING\tSSO TOKEN\tCACHE FILE")
+	for _, st := range statuses {
+		if st.Error != "" {
+			fmt.Fprintf(tw, "%s\terror\t-\t-\t%s\n", st.Profile, st.Error)
+			continue
+		}
+		creds, remaining := "missing", "-"
+		if st.CredentialsCached {
+			creds = "expired"
+			if st.CredentialsRemaining > 0 {
+				creds = "valid"
+				remaining = (time.Duration(st.CredentialsRemaining) * time.Second).String()
+			}
+		}
+		token := "missing"
+		if st.SSOTokenExpiration != "" {
+			token = "expired"
+			if st.SSOTokenValid {
+				token = "valid"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", st.Profile, creds, remaining, token, st.CacheFile)
+	}
+	_ = tw.Flush()
+}
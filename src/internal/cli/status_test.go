@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+	"github.com/witnsby/aws-sso-login/src/internal/sso"
+)
+
+// captureStatus redirects statusOutput for the duration of the test.
+func captureStatus(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	orig := statusOutput
+	t.Cleanup(func() { statusOutput = orig })
+	statusOutput = &buf
+	return &buf
+}
+
+func TestStatus(t *testing.T) {
+	writeAwsConfig(t, `[profile cached]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Admin
+
+[profile empty]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = ReadOnly
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+`)
+	dir := isolateAwsPaths(t)
+
+	profile, err := retrieveProfile("cached")
+	if err != nil {
+		t.Fatalf("retrieve fixture profile: %v", err)
+	}
+	expiration := time.Now().Add(time.Hour).UTC().Format(sso.TimeFormat)
+	if err := writeCachedRoleCredentials(profile, &model.RoleCredential{
+		AccessKeyId: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token", Expiration: expiration,
+	}); err != nil {
+		t.Fatalf("write cache fixture: %v", err)
+	}
+	if err := sso.SaveToken(filepath.Join(dir, "sso", "cache"), sso.TokenCacheKey("corp", ""), &sso.CachedToken{
+		StartURL:    "https://example.awsapps.com/start",
+		AccessToken: "access-token",
+		ExpiresAt:   time.Now().Add(-time.Minute).UTC().Format(sso.TimeFormat),
+	}); err != nil {
+		t.Fatalf("write token fixture: %v", err)
+	}
+
+	out := captureStatus(t)
+	if err := status("", "json"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var statuses []profileStatus
+	if err := json.Unmarshal(out.Bytes(), &statuses); err != nil {
+		t.Fatalf("decode JSON %q: %v", out.String(), err)
+	}
+	if len(statuses) != 2 || statuses[0].Profile != "cached" || statuses[1].Profile != "empty" {
+		t.Fatalf("unexpected profiles %+v", statuses)
+	}
+	cached := statuses[0]
+	if !cached.CredentialsCached || cached.CredentialsRemaining < 3500 || cached.CredentialsExpiration != expiration {
+		t.Fatalf("unexpected credential status %+v", cached)
+	}
+	if cached.SSOTokenValid || cached.SSOTokenExpiration == "" {
+		t.Fatalf("expected an expired SSO token, got %+v", cached)
+	}
+	if !strings.HasPrefix(cached.CacheFile, filepath.Join(dir, "cli", "cache")) {
+		t.Fatalf("unexpected cache file %q", cached.CacheFile)
+	}
+	if statuses[1].CredentialsCached {
+		t.Fatalf("expected no cached credentials, got %+v", statuses[1])
+	}
+
+	out.Reset()
+	if err := status("empty", "table"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "PROFILE") || !strings.Contains(lines[1], "missing") {
+		t.Fatalf("unexpected table:\n%s", out.String())
+	}
+
+	if err := status("", "yaml"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
+
+func TestCredentialExpiration(t *testing.T) {
+	cases := map[string]string{
+		"2026-01-01T00:00:00Z":     "2026-01-01T00:00:00Z",
+		"2026-01-01T02:00:00+0200": "2026-01-01T00:00:00Z",
+		"2026-01-01T00:00:00UTC":   "2026-01-01T00:00:00Z",
+		"not a timestamp":          "not a timestamp",
+	}
+	for in, want := range cases {
+		if got := credentialExpiration(in); got != want {
+			t.Errorf("credentialExpiration(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Command capture regenerates the testdata/cli-cache/cache fixtures by
+// running the real AWS CLI v2 (`aws sts get-caller-identity`) for each
+// profile in testdata/cli-cache/config and copying the cache files it writes.
+//
+// The CLI is pointed at a local fake of the SSO portal and STS through
+// AWS_ENDPOINT_URL_SSO and AWS_ENDPOINT_URL_STS, inside a temporary HOME, so
+// no AWS account is contacted and the user's ~/.aws is never touched. The
+// fake hands out placeholder credentials whose access key ID names the
+// profile shape (ASIALEGACY, ASIADEPLOY, ...), which the tests look for.
+//
+// Run from src/internal/cli with aws v2 on PATH:
+//
+//	go run ./testdata/cli-cache/capture
+//
+// chained-full has an mfa_serial, so the CLI asks for a code; any six digits
+// will do.
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// expiration keeps the captured credentials valid for the tests.
+var expiration = time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ssoTokens are the cached SSO tokens the CLI reads, keyed the way it looks
+// them up: sha1 of the sso-session name, or of the start URL for legacy
+// profiles. Each access token selects the key ID the fake returns.
+var ssoTokens = map[string]string{
+	"corp":                              "SESSION",
+	"équipe":                            "UNICODE",
+	"https://example.awsapps.com/start": "LEGACY",
+}
+
+// profiles are captured in order; chained ones reuse the SSO entry of their
+// source profile.
+var profiles = []string{"legacy", "session", "unicode-session", "chained-minimal", "chained-full"}
+
+func main() {
+	dir := flag.String("dir", filepath.Join("testdata", "cli-cache"), "fixture directory holding config and cache/")
+	flag.Parse()
+	if err := capture(*dir); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func capture(dir string) error {
+	config, err := filepath.Abs(filepath.Join(dir, "config"))
+	if err != nil {
+		return err
+	}
+	home, err := os.MkdirTemp("", "cli-cache-capture")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(home)
+
+	if err := writeSSOTokens(filepath.Join(home, ".aws", "sso", "cache")); err != nil {
+		return err
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(serveFake))
+	defer srv.Close()
+
+	env := append(os.Environ(),
+		"HOME="+home,
+		"AWS_CONFIG_FILE="+config,
+		"AWS_SHARED_CREDENTIALS_FILE="+filepath.Join(home, "credentials"),
+		"AWS_ENDPOINT_URL_SSO="+srv.URL,
+		"AWS_ENDPOINT_URL_STS="+srv.URL,
+		"AWS_REGION=us-east-1",
+		"AWS_PAGER=",
+	)
+	for _, profile := range profiles {
+		cmd := exec.Command("aws", "sts", "get-caller-identity", "--profile", profile)
+		cmd.Env = env
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("aws sts get-caller-identity --profile %s: %w", profile, err)
+		}
+	}
+
+	return replaceFixtures(filepath.Join(home, ".aws", "cli", "cache"), filepath.Join(dir, "cache"))
+}
+
+func writeSSOTokens(cacheDir string) error {
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		return err
+	}
+	for name, token := range ssoTokens {
+		sum := sha1.Sum([]byte(name))
+		data, err := json.Marshal(map[string]string{
+			"startUrl":    "https://example.awsapps.com/start",
+			"region":      "us-east-1",
+			"accessToken": token,
+			"expiresAt":   expiration.Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".json"), data, 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceFixtures swaps the captured entries in for the previous ones. The
+// hand-written unknown-*.json scan fixtures are kept.
+func replaceFixtures(from, to string) error {
+	old, err := filepath.Glob(filepath.Join(to, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range old {
+		if !strings.HasPrefix(filepath.Base(path), "unknown-") {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	captured, err := filepath.Glob(filepath.Join(from, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range captured {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(to, filepath.Base(path)), data, 0o644); err != nil {
+			return err
+		}
+		log.Printf("captured %s", filepath.Base(path))
+	}
+	return nil
+}
+
+// serveFake answers SSO GetRoleCredentials and the STS AssumeRole and
+// GetCallerIdentity actions, in both the query and the JSON protocol.
+func serveFake(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/federation/credentials" {
+		token := r.Header.Get("x-amz-sso_bearer_token")
+		_ = json.NewEncoder(w).Encode(map[string]any{"roleCredentials": map[string]any{
+			"accessKeyId":     "ASIA" + token,
+			"secretAccessKey": "fixture-secret",
+			"sessionToken":    "fixture-token",
+			"expiration":      expiration.UnixMilli(),
+		}})
+		return
+	}
+
+	action, params := stsRequest(r)
+	type credentials struct {
+		AccessKeyId     string
+		SecretAccessKey string
+		SessionToken    string
+		Expiration      string
+	}
+	type assumedRoleUser struct {
+		AssumedRoleId string
+		Arn           string
+	}
+	var result any
+	switch action {
+	case "AssumeRole":
+		roleArn := params["RoleArn"]
+		role := roleArn[strings.LastIndex(roleArn, "/")+1:]
+		session := params["RoleSessionName"]
+		result = struct {
+			Credentials     credentials
+			AssumedRoleUser assumedRoleUser
+		}{
+			credentials{"ASIA" + strings.ToUpper(role), "fixture-secret", "fixture-token", expiration.Format(time.RFC3339)},
+			assumedRoleUser{"AROAEXAMPLE:" + session, "arn:aws:sts::222222222222:assumed-role/" + role + "/" + session},
+		}
+	case "GetCallerIdentity":
+		result = struct{ UserId, Account, Arn string }{"AROAEXAMPLE:fixture", "222222222222", "arn:aws:sts::222222222222:assumed-role/Fixture/fixture"}
+	default:
+		http.Error(w, "unsupported action "+action, http.StatusBadRequest)
+		return
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-amz-json") {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_ = json.NewEncoder(w).Encode(result)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName  xml.Name
+		Xmlns    string `xml:"xmlns,attr"`
+		Result   any    `xml:"Result"`
+		Metadata struct {
+			RequestId string
+		} `xml:"ResponseMetadata"`
+	}{
+		XMLName: xml.Name{Local: action + "Response"},
+		Xmlns:   "https://sts.amazonaws.com/doc/2011-06-15/",
+		Result:  xmlResult{name: action + "Result", value: result},
+	})
+}
+
+// stsRequest returns the action and parameters of a query or JSON protocol
+// STS request.
+func stsRequest(r *http.Request) (string, map[string]string) {
+	params := map[string]string{}
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		return target[strings.LastIndex(target, ".")+1:], params
+	}
+	_ = r.ParseForm()
+	for key := range r.Form {
+		params[key] = r.Form.Get(key)
+	}
+	return params["Action"], params
+}
+
+// xmlResult encodes value under the element name, e.g. AssumeRoleResult.
+type xmlResult struct {
+	name  string
+	value any
+}
+
+func (x xmlResult) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name.Local = x.name
+	return e.EncodeElement(x.value, start)
+}
@@ -0,0 +1,207 @@
+// Package credserver serves role credentials over the HTTP protocols the AWS
+// SDKs use to fetch credentials from a container agent
+// (AWS_CONTAINER_CREDENTIALS_FULL_URI) or from the EC2 instance metadata
+// service (IMDSv2).
+package credserver
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+)
+
+// Provider returns unexpired role credentials. It is called for every
+// credential request, so it is expected to cache and refresh on its own.
+type Provider func() (*model.RoleCredential, error)
+
+// maxIMDSTokenTTL is the longest session token lifetime IMDSv2 grants.
+const maxIMDSTokenTTL = 6 * time.Hour
+
+// now is a seam for tests.
+var now = time.Now
+
+// NewToken returns a random hex token suitable for AWS_CONTAINER_AUTHORIZATION_TOKEN.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ListenLoopback listens on addr and refuses anything but a loopback address,
+// since the endpoints hand out credentials to whoever can reach them.
+func ListenLoopback(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	ip := net.ParseIP(host)
+	if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("listen address %q is not a loopback address", addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// ECSHandler serves credentials in the container credentials format. Every
+// request must carry token in the Authorization header, as the SDKs send the
+// value of AWS_CONTAINER_AUTHORIZATION_TOKEN.
+func ECSHandler(token string, provider Provider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid authorization token")
+			return
+		}
+		cred, err := provider()
+		if err != nil {
+			logrus.Errorf("Failed to provide credentials: %v", err)
+			writeError(w, http.StatusInternalServerError, "credentials unavailable")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{
+			"AccessKeyId":     cred.AccessKeyId,
+			"SecretAccessKey": cred.SecretAccessKey,
+			"Token":           cred.SessionToken,
+			"Expiration":      cred.Expiration,
+		})
+	})
+}
+
+// IMDSHandler serves the subset of the IMDSv2 API the SDKs use for
+// credentials: session tokens, the role listing, the role credentials and
+// (when region is set) the placement region. Requests without a valid session
+// token, and token requests that were forwarded by a proxy, are rejected as
+// the real service does.
+func IMDSHandler(roleName, region string, provider Provider) http.Handler {
+	s := &imds{roleName: roleName, region: region, provider: provider, tokens: map[string]time.Time{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest/api/token", s.token)
+	mux.HandleFunc("/latest/meta-data/iam/security-credentials/", s.credentials)
+	mux.HandleFunc("/latest/meta-data/placement/region", s.placementRegion)
+	return mux
+}
+
+// imds holds the state of an IMDSHandler.
+type imds struct {
+	roleName string
+	region   string
+	provider Provider
+
+	mu     sync.Mutex
+	tokens map[string]time.Time // session token -> expiry
+}
+
+func (s *imds) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if r.Header.Get("X-Forwarded-For") != "" {
+		writeError(w, http.StatusForbidden, "forwarded requests are not allowed")
+		return
+	}
+	secs, err := strconv.Atoi(r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+	ttl := time.Duration(secs) * time.Second
+	if err != nil || ttl < time.Second || ttl > maxIMDSTokenTTL {
+		writeError(w, http.StatusBadRequest, "invalid X-aws-ec2-metadata-token-ttl-seconds")
+		return
+	}
+	token, err := NewToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "could not generate token")
+		return
+	}
+
+	s.mu.Lock()
+	t := now()
+	for tok, exp := range s.tokens {
+		if !exp.After(t) {
+			delete(s.tokens, tok)
+		}
+	}
+	s.tokens[token] = t.Add(ttl)
+	s.mu.Unlock()
+
+	w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(secs))
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(token))
+}
+
+// authorized reports whether r carries an unexpired session token.
+func (s *imds) authorized(r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp, ok := s.tokens[r.Header.Get("X-aws-ec2-metadata-token")]
+	return ok && exp.After(now())
+}
+
+func (s *imds) credentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "missing or expired session token")
+		return
+	}
+	switch r.URL.Path[len("/latest/meta-data/iam/security-credentials/"):] {
+	case "":
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(s.roleName))
+	case s.roleName:
+		cred, err := s.provider()
+		if err != nil {
+			logrus.Errorf("Failed to provide credentials: %v", err)
+			writeError(w, http.StatusInternalServerError, "credentials unavailable")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{
+			"Code":            "Success",
+			"LastUpdated":     now().UTC().Format(time.RFC3339),
+			"Type":            "AWS-HMAC",
+			"AccessKeyId":     cred.AccessKeyId,
+			"SecretAccessKey": cred.SecretAccessKey,
+			"Token":           cred.SessionToken,
+			"Expiration":      cred.Expiration,
+		})
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *imds) placementRegion(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "missing or expired session token")
+		return
+	}
+	if s.region == "" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(s.region))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
@@ -0,0 +1,126 @@
+package credserver
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/witnsby/aws-sso-login/src/internal/model"
+)
+
+func staticProvider() (*model.RoleCredential, error) {
+	return &model.RoleCredential{
+		AccessKeyId:     "ASIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+		Expiration:      "2026-01-01T00:00:00Z",
+	}, nil
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path string, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, nil)
+	require.NoError(t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestECSHandler(t *testing.T) {
+	srv := httptest.NewServer(ECSHandler("secret-token", staticProvider))
+	t.Cleanup(srv.Close)
+
+	resp, _ := do(t, srv, http.MethodGet, "/", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp, _ = do(t, srv, http.MethodGet, "/", map[string]string{"Authorization": "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, body := do(t, srv, http.MethodGet, "/", map[string]string{"Authorization": "secret-token"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var got map[string]string
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+	assert.Equal(t, map[string]string{
+		"AccessKeyId":     "ASIAEXAMPLE",
+		"SecretAccessKey": "secret",
+		"Token":           "session",
+		"Expiration":      "2026-01-01T00:00:00Z",
+	}, got)
+
+	failing := httptest.NewServer(ECSHandler("t", func() (*model.RoleCredential, error) {
+		return nil, errors.New("SSO login required")
+	}))
+	t.Cleanup(failing.Close)
+	resp, body = do(t, failing, http.MethodGet, "/", map[string]string{"Authorization": "t"})
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.NotContains(t, body, "SSO login required")
+}
+
+func TestIMDSHandler(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	orig := now
+	t.Cleanup(func() { now = orig })
+	now = func() time.Time { return clock }
+
+	srv := httptest.NewServer(IMDSHandler("Admin", "eu-west-1", staticProvider))
+	t.Cleanup(srv.Close)
+
+	resp, _ := do(t, srv, http.MethodGet, "/latest/meta-data/iam/security-credentials/", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = do(t, srv, http.MethodPut, "/latest/api/token", map[string]string{
+		"X-aws-ec2-metadata-token-ttl-seconds": "60", "X-Forwarded-For": "10.0.0.1"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = do(t, srv, http.MethodPut, "/latest/api/token", map[string]string{
+		"X-aws-ec2-metadata-token-ttl-seconds": "0"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, token := do(t, srv, http.MethodPut, "/latest/api/token", map[string]string{
+		"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	auth := map[string]string{"X-aws-ec2-metadata-token": token}
+
+	_, body := do(t, srv, http.MethodGet, "/latest/meta-data/iam/security-credentials/", auth)
+	assert.Equal(t, "Admin", body)
+
+	resp, body = do(t, srv, http.MethodGet, "/latest/meta-data/iam/security-credentials/Admin", auth)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var got map[string]string
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+	assert.Equal(t, "Success", got["Code"])
+	assert.Equal(t, "ASIAEXAMPLE", got["AccessKeyId"])
+	assert.Equal(t, "session", got["Token"])
+
+	resp, _ = do(t, srv, http.MethodGet, "/latest/meta-data/iam/security-credentials/Other", auth)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	_, body = do(t, srv, http.MethodGet, "/latest/meta-data/placement/region", auth)
+	assert.Equal(t, "eu-west-1", body)
+
+	// Session tokens expire after their TTL.
+	clock = clock.Add(2 * time.Minute)
+	resp, _ = do(t, srv, http.MethodGet, "/latest/meta-data/iam/security-credentials/Admin", auth)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestListenLoopback(t *testing.T) {
+	ln, err := ListenLoopback("127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, ln.Close())
+
+	_, err = ListenLoopback("0.0.0.0:0")
+	assert.ErrorContains(t, err, "not a loopback address")
+	_, err = ListenLoopback("192.0.2.1:9911")
+	assert.ErrorContains(t, err, "not a loopback address")
+}
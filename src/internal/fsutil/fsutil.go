@@ -0,0 +1,124 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockTimeout bounds how long Lock waits for another process to release the lock.
+var LockTimeout = 30 * time.Second
+
+// lockPollInterval is how often Lock retries a contended lock.
+const lockPollInterval = 50 * time.Millisecond
+
+// BackupTimeFormat is the timestamp suffix used for backup file names.
+const BackupTimeFormat = "20060102T150405"
+
+// Lock takes an exclusive advisory lock guarding path and returns a function
+// that releases it. The lock is held on a sibling "<path>.lock" file rather
+// than on path itself, because WriteFileAtomic replaces path's inode. The
+// parent directory is created if needed.
+func Lock(path string) (func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	lockPath := path + ".lock"
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		err = tryLock(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLocked) || time.Now().After(deadline) {
+			_ = f.Close()
+			if errors.Is(err, errLocked) {
+				return nil, fmt.Errorf("timed out after %s waiting for lock on %s", LockTimeout, lockPath)
+			}
+			return nil, fmt.Errorf("locking %s: %w", lockPath, err)
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	return func() error {
+		unlockErr := unlock(f)
+		closeErr := f.Close()
+		return errors.Join(unlockErr, closeErr)
+	}, nil
+}
+
+// WriteFileAtomic writes data to a temporary file in path's directory and
+// renames it over path, so readers never observe a truncated file. The mode of
+// an existing file is preserved; new files get perm.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	// Remove the temp file on any failure; after a successful rename it is gone.
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Backup copies path to "<path>.<timestamp>.bak" with the same mode and
+// returns the backup path. It returns "", nil when path does not exist.
+func Backup(path string, now time.Time) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+	backupPath := fmt.Sprintf("%s.%s.bak", path, now.Format(BackupTimeFormat))
+	dst, err := os.OpenFile(backupPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return "", err
+	}
+	return backupPath, dst.Close()
+}
@@ -0,0 +1,102 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic_NewFileUsesPerm(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "nested", "credentials")
+
+	require.NoError(t, WriteFileAtomic(path, []byte("[a]\n"), 0o600))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "[a]\n", string(data))
+}
+
+func TestWriteFileAtomic_PreservesModeAndLeavesNoTemp(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0o640))
+	require.NoError(t, os.Chmod(path, 0o640))
+
+	require.NoError(t, WriteFileAtomic(path, []byte("new"), 0o600))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary file should have been renamed away")
+}
+
+func TestBackup(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials")
+	now := time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	backupPath, err := Backup(path, now)
+	require.NoError(t, err)
+	assert.Empty(t, backupPath, "missing source should not produce a backup")
+
+	require.NoError(t, os.WriteFile(path, []byte("contents"), 0o600))
+	backupPath, err = Backup(path, now)
+	require.NoError(t, err)
+	assert.Equal(t, path+".20260506T070809.bak", backupPath)
+
+	data, err := os.ReadFile(backupPath)
+	require.NoError(t, err)
+	assert.Equal(t, "contents", string(data))
+	info, err := os.Stat(backupPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+// TestLock_SerializesReadModifyWrite runs concurrent load-modify-save cycles
+// on a counter file; without mutual exclusion increments would be lost.
+func TestLock_SerializesReadModifyWrite(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "counter")
+	require.NoError(t, os.WriteFile(path, []byte("0"), 0o600))
+
+	const workers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := Lock(path)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() { assert.NoError(t, unlock()) }()
+
+			data, err := os.ReadFile(path)
+			if !assert.NoError(t, err) {
+				return
+			}
+			n, _ := strconv.Atoi(string(data))
+			time.Sleep(time.Millisecond)
+			assert.NoError(t, WriteFileAtomic(path, []byte(strconv.Itoa(n+1)), 0o600))
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(workers), string(data))
+}
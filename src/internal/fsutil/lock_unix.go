@@ -0,0 +1,24 @@
+//go:build !windows
+
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errLocked reports that another process holds the lock.
+var errLocked = errors.New("file is locked")
+
+func tryLock(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errLocked
+	}
+	return err
+}
+
+func unlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -2,36 +2,84 @@ package helper
 
 import (
 	"fmt"
+	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 )
 
 // ----------------------------------------------------------------------------
 // Paths & File helpers
 // ----------------------------------------------------------------------------
 
+// Path overrides bound to the global command-line flags. A non-empty value
+// takes precedence over the corresponding environment variable, which in turn
+// takes precedence over the default location under ~/.aws.
+var (
+	ConfigFileFlag      string // --config-file
+	CredentialsFileFlag string // --credentials-file
+	CliCacheDirFlag     string // --cli-cache-dir
+	SSOCacheDirFlag     string // --sso-cache-dir
+)
+
+// Environment variables consulted when the matching flag is not set.
+const (
+	ConfigFileEnv      = "AWS_CONFIG_FILE"
+	CredentialsFileEnv = "AWS_SHARED_CREDENTIALS_FILE"
+	CliCacheDirEnv     = "AWS_SSO_LOGIN_CLI_CACHE_DIR"
+	SSOCacheDirEnv     = "AWS_SSO_LOGIN_SSO_CACHE_DIR"
+)
+
+// GetAwsConfigPath returns the AWS config file path.
+// By default: ~/.aws/config
+func GetAwsConfigPath() (string, error) {
+	return resolvePath(ConfigFileFlag, ConfigFileEnv, ".aws", "config")
+}
+
+// GetAwsCredentialsPath returns the shared credentials file path.
+// By default: ~/.aws/credentials
 func GetAwsCredentialsPath() (string, error) {
-	// By default: ~/.aws/credentials
-	usr, err := user.Current()
-	if err != nil {
-		return "", err
-	}
-	return filepath.Join(usr.HomeDir, ".aws", "credentials"), nil
+	return resolvePath(CredentialsFileFlag, CredentialsFileEnv, ".aws", "credentials")
 }
 
+// GetAwsCliCachePath returns the AWS CLI role credentials cache directory.
+// By default: ~/.aws/cli/cache
 func GetAwsCliCachePath() (string, error) {
-	// By default: ~/.aws/cli/cache
+	return resolvePath(CliCacheDirFlag, CliCacheDirEnv, ".aws", "cli", "cache")
+}
+
+// GetAwsSSOCachePath returns the SSO access token cache directory.
+// By default: ~/.aws/sso/cache
+func GetAwsSSOCachePath() (string, error) {
+	return resolvePath(SSOCacheDirFlag, SSOCacheDirEnv, ".aws", "sso", "cache")
+}
+
+// resolvePath applies the flag > environment variable > home-relative default
+// precedence shared by all path helpers. A leading "~" is expanded in flag and
+// environment values, as the AWS CLI does.
+func resolvePath(flagValue, envName string, defaultElems ...string) (string, error) {
+	if flagValue != "" {
+		return expandHome(flagValue)
+	}
+	if envValue := os.Getenv(envName); envValue != "" {
+		return expandHome(envValue)
+	}
+
 	usr, err := user.Current()
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("unable to retrieve current user: %w", err)
 	}
-	return filepath.Join(usr.HomeDir, ".aws", "cli", "cache"), nil
+	return filepath.Join(append([]string{usr.HomeDir}, defaultElems...)...), nil
 }
 
-func ConsoleUrl(region string) string {
-	return fmt.Sprintf("https://%s.console.aws.amazon.com/", region)
-}
-
-func ConsoleLogout(region string) string {
-	return fmt.Sprintf("https://%s.console.aws.amazon.com/console/logout!doLogout", region)
+// expandHome replaces a leading "~" with the current user's home directory.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path, nil
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve current user: %w", err)
+	}
+	return filepath.Join(usr.HomeDir, path[1:]), nil
 }
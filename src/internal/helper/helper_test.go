@@ -0,0 +1,51 @@
+package helper
+
+import (
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathPrecedence(t *testing.T) {
+	usr, err := user.Current()
+	require.NoError(t, err)
+
+	cases := []struct {
+		name    string
+		resolve func() (string, error)
+		flag    *string
+		env     string
+		def     string
+	}{
+		{"config", GetAwsConfigPath, &ConfigFileFlag, ConfigFileEnv, filepath.Join(usr.HomeDir, ".aws", "config")},
+		{"credentials", GetAwsCredentialsPath, &CredentialsFileFlag, CredentialsFileEnv, filepath.Join(usr.HomeDir, ".aws", "credentials")},
+		{"cli cache", GetAwsCliCachePath, &CliCacheDirFlag, CliCacheDirEnv, filepath.Join(usr.HomeDir, ".aws", "cli", "cache")},
+		{"sso cache", GetAwsSSOCachePath, &SSOCacheDirFlag, SSOCacheDirEnv, filepath.Join(usr.HomeDir, ".aws", "sso", "cache")},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			orig := *tc.flag
+			t.Cleanup(func() { *tc.flag = orig })
+
+			*tc.flag = ""
+			t.Setenv(tc.env, "")
+			got, err := tc.resolve()
+			require.NoError(t, err)
+			assert.Equal(t, tc.def, got, "default")
+
+			t.Setenv(tc.env, "~/from-env")
+			got, err = tc.resolve()
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(usr.HomeDir, "from-env"), got, "env with ~ expansion")
+
+			*tc.flag = "/from/flag"
+			got, err = tc.resolve()
+			require.NoError(t, err)
+			assert.Equal(t, "/from/flag", got, "flag beats env")
+		})
+	}
+}
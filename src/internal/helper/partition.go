@@ -0,0 +1,114 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Partition describes the sign-in and console endpoints of an AWS partition.
+type Partition struct {
+	ID            string // aws, aws-us-gov or aws-cn
+	SigninDomain  string // host of the federation endpoint
+	ConsoleDomain string // console host, optionally prefixed with "<region>."
+	DNSSuffix     string // suffix of regional service endpoints
+	// RegionalConsole is true when the console is served from <region>.ConsoleDomain
+	// and sign-in accepts the <account>.SigninDomain host.
+	RegionalConsole bool
+}
+
+// Known partitions.
+var (
+	PartitionAWS = Partition{
+		ID:              "aws",
+		SigninDomain:    "signin.aws.amazon.com",
+		ConsoleDomain:   "console.aws.amazon.com",
+		DNSSuffix:       "amazonaws.com",
+		RegionalConsole: true,
+	}
+	PartitionAWSUSGov = Partition{
+		ID:            "aws-us-gov",
+		SigninDomain:  "signin.amazonaws-us-gov.com",
+		ConsoleDomain: "console.amazonaws-us-gov.com",
+		DNSSuffix:     "amazonaws.com",
+	}
+	PartitionAWSCN = Partition{
+		ID:            "aws-cn",
+		SigninDomain:  "signin.amazonaws.cn",
+		ConsoleDomain: "console.amazonaws.cn",
+		DNSSuffix:     "amazonaws.com.cn",
+	}
+)
+
+var partitionsByID = map[string]Partition{
+	PartitionAWS.ID:      PartitionAWS,
+	PartitionAWSUSGov.ID: PartitionAWSUSGov,
+	PartitionAWSCN.ID:    PartitionAWSCN,
+}
+
+// ResolvePartition returns the partition named by override when it is set,
+// otherwise the partition region belongs to (us-gov-* and cn-* regions map to
+// aws-us-gov and aws-cn; everything else to aws).
+func ResolvePartition(override, region string) (Partition, error) {
+	if override != "" {
+		p, ok := partitionsByID[override]
+		if !ok {
+			return Partition{}, fmt.Errorf("unknown partition %q (valid: aws, aws-us-gov, aws-cn)", override)
+		}
+		return p, nil
+	}
+	return PartitionForRegion(region), nil
+}
+
+// PartitionForRegion returns the partition a region name belongs to.
+func PartitionForRegion(region string) Partition {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return PartitionAWSUSGov
+	case strings.HasPrefix(region, "cn-"):
+		return PartitionAWSCN
+	default:
+		return PartitionAWS
+	}
+}
+
+// FederationURL returns the federation endpoint used to obtain sign-in tokens.
+func (p Partition) FederationURL() string {
+	return fmt.Sprintf("https://%s/federation", p.SigninDomain)
+}
+
+// SigninURL returns the federation endpoint used to redeem a sign-in token.
+// In the aws partition the account-specific host is used, which keeps the
+// account alias visible in the browser.
+func (p Partition) SigninURL(account string) string {
+	if p.RegionalConsole && account != "" {
+		return fmt.Sprintf("https://%s.%s/federation", account, p.SigninDomain)
+	}
+	return p.FederationURL()
+}
+
+// ConsoleURL returns the console home page for region.
+func (p Partition) ConsoleURL(region string) string {
+	if p.RegionalConsole {
+		return fmt.Sprintf("https://%s.%s/", region, p.ConsoleDomain)
+	}
+	return fmt.Sprintf("https://%s/console/home?region=%s", p.ConsoleDomain, region)
+}
+
+// ConsoleBaseURL returns the console origin that relative paths are resolved against.
+func (p Partition) ConsoleBaseURL(region string) string {
+	if p.RegionalConsole {
+		return fmt.Sprintf("https://%s.%s", region, p.ConsoleDomain)
+	}
+	return fmt.Sprintf("https://%s", p.ConsoleDomain)
+}
+
+// LogoutURL returns the console logout page.
+func (p Partition) LogoutURL(region string) string {
+	return p.ConsoleBaseURL(region) + "/console/logout!doLogout"
+}
+
+// IsConsoleHost reports whether host belongs to this partition's console.
+func (p Partition) IsConsoleHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == p.ConsoleDomain || strings.HasSuffix(host, "."+p.ConsoleDomain)
+}
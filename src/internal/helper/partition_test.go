@@ -0,0 +1,50 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePartition(t *testing.T) {
+	cases := map[string]string{
+		"us-east-1":      "aws",
+		"eu-central-1":   "aws",
+		"us-gov-west-1":  "aws-us-gov",
+		"us-gov-east-1":  "aws-us-gov",
+		"cn-north-1":     "aws-cn",
+		"cn-northwest-1": "aws-cn",
+		"":               "aws",
+	}
+	for region, want := range cases {
+		p, err := ResolvePartition("", region)
+		require.NoError(t, err)
+		assert.Equal(t, want, p.ID, region)
+	}
+
+	p, err := ResolvePartition("aws-cn", "us-east-1")
+	require.NoError(t, err)
+	assert.Equal(t, PartitionAWSCN, p)
+
+	_, err = ResolvePartition("aws-iso", "us-east-1")
+	assert.ErrorContains(t, err, "unknown partition")
+}
+
+func TestPartitionEndpoints(t *testing.T) {
+	assert.Equal(t, "https://signin.aws.amazon.com/federation", PartitionAWS.FederationURL())
+	assert.Equal(t, "https://123456789012.signin.aws.amazon.com/federation", PartitionAWS.SigninURL("123456789012"))
+	assert.Equal(t, "https://eu-west-1.console.aws.amazon.com/", PartitionAWS.ConsoleURL("eu-west-1"))
+	assert.Equal(t, "https://eu-west-1.console.aws.amazon.com/console/logout!doLogout", PartitionAWS.LogoutURL("eu-west-1"))
+
+	assert.Equal(t, "https://signin.amazonaws-us-gov.com/federation", PartitionAWSUSGov.SigninURL("123456789012"))
+	assert.Equal(t, "https://console.amazonaws-us-gov.com/console/home?region=us-gov-west-1", PartitionAWSUSGov.ConsoleURL("us-gov-west-1"))
+	assert.Equal(t, "https://console.amazonaws-us-gov.com/console/logout!doLogout", PartitionAWSUSGov.LogoutURL("us-gov-west-1"))
+
+	assert.Equal(t, "https://signin.amazonaws.cn/federation", PartitionAWSCN.FederationURL())
+	assert.Equal(t, "https://console.amazonaws.cn/console/home?region=cn-north-1", PartitionAWSCN.ConsoleURL("cn-north-1"))
+
+	assert.True(t, PartitionAWS.IsConsoleHost("US-EAST-1.console.aws.amazon.com"))
+	assert.False(t, PartitionAWS.IsConsoleHost("console.aws.amazon.com.evil.example"))
+	assert.False(t, PartitionAWSCN.IsConsoleHost("console.aws.amazon.com"))
+}
@@ -1,11 +1,20 @@
 package helper
 
+import "time"
+
 const ErrorPofileSpecification = "must specify --profile"
-const SessionDuration = "43200" //12 hours
+
+// Console session limits accepted by the federation endpoint.
+const (
+	MinSessionDuration = 15 * time.Minute
+	MaxSessionDuration = 12 * time.Hour
+)
 const (
 	ISO8601WithOffset    = "2006-01-02T15:04:05-0700"
 	ISO8601WithFixedZone = "2006-01-02T15:04:05Z0700"
 	ISO8601WithUTC       = "2006-01-02T15:04:05UTC"
+	// ISO8601WithColonOffset is how the AWS CLI writes assumed-role expirations.
+	ISO8601WithColonOffset = "2006-01-02T15:04:05Z07:00"
 )
 
 var Version = "dev"
@@ -15,4 +24,5 @@ var TimeLayouts = []string{
 	ISO8601WithOffset,
 	ISO8601WithFixedZone,
 	ISO8601WithUTC,
+	ISO8601WithColonOffset,
 }
@@ -0,0 +1,284 @@
+// Package inifile edits AWS-style INI files line by line. Everything that is
+// not explicitly changed (comments, blank lines, section order, key spelling,
+// spacing around "=", line endings) is written back byte for byte, which
+// go-ini's load/save cycle does not guarantee.
+package inifile
+
+import (
+	"strings"
+)
+
+// line is one physical line and its original terminator ("\n", "\r\n" or
+// "" for a final line without one).
+type line struct {
+	text string
+	eol  string
+}
+
+// File is a parsed INI document.
+type File struct {
+	lines []line
+	// eol terminates lines added by edits; it follows the file's first line.
+	eol string
+}
+
+// Parse splits data into lines. It never fails: lines that are neither
+// section headers nor keys are kept as opaque text.
+func Parse(data []byte) *File {
+	f := &File{eol: "\n"}
+	s := string(data)
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			f.lines = append(f.lines, line{text: s})
+			break
+		}
+		text, eol := s[:i], "\n"
+		if strings.HasSuffix(text, "\r") {
+			text, eol = text[:len(text)-1], "\r\n"
+		}
+		f.lines = append(f.lines, line{text: text, eol: eol})
+		s = s[i+1:]
+	}
+	if len(f.lines) > 0 && f.lines[0].eol != "" {
+		f.eol = f.lines[0].eol
+	}
+	return f
+}
+
+// Bytes renders the document.
+func (f *File) Bytes() []byte {
+	var b strings.Builder
+	for _, l := range f.lines {
+		b.WriteString(l.text)
+		b.WriteString(l.eol)
+	}
+	return []byte(b.String())
+}
+
+// Sections returns the distinct section names in order of first appearance.
+func (f *File) Sections() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, l := range f.lines {
+		if name, ok := sectionName(l.text); ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// HasSection reports whether a section named name exists.
+func (f *File) HasSection(name string) bool {
+	return len(f.spans(name)) > 0
+}
+
+// Get returns the value of key in section. When a section or key is
+// repeated, the last occurrence wins, matching go-ini and the AWS CLI.
+func (f *File) Get(section, key string) (string, bool) {
+	value, found := "", false
+	for _, sp := range f.spans(section) {
+		for i := sp.start + 1; i < sp.end; i++ {
+			if k, v, ok := keyValue(f.lines[i].text); ok && k == key {
+				value, found = v, true
+			}
+		}
+	}
+	return value, found
+}
+
+// Keys returns the distinct key names of section in order of first appearance.
+func (f *File) Keys(section string) []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, sp := range f.spans(section) {
+		for i := sp.start + 1; i < sp.end; i++ {
+			if k, _, ok := keyValue(f.lines[i].text); ok && !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
+
+// Set assigns value to key in section. Existing occurrences of the key are
+// rewritten in place, keeping their spacing; otherwise the key is appended
+// after the last line of the section's last occurrence, before any trailing
+// blank lines and before comments that lead into the next section. A missing
+// section is appended to the end of the file, separated by a blank line.
+func (f *File) Set(section, key, value string) {
+	spans := f.spans(section)
+	if len(spans) == 0 {
+		if n := len(f.lines); n > 0 && strings.TrimSpace(f.lines[n-1].text) != "" {
+			f.insert(n, line{eol: f.eol})
+		}
+		f.insert(len(f.lines), line{text: "[" + section + "]", eol: f.eol})
+		f.insert(len(f.lines), line{text: key + " = " + value, eol: f.eol})
+		return
+	}
+
+	updated := false
+	for i := len(spans) - 1; i >= 0; i-- {
+		sp := spans[i]
+		for j := sp.end - 1; j > sp.start; j-- {
+			if k, _, ok := keyValue(f.lines[j].text); ok && k == key {
+				f.replaceKey(j, value)
+				updated = true
+			}
+		}
+	}
+	if updated {
+		return
+	}
+
+	last := spans[len(spans)-1]
+	at := f.bodyEnd(last)
+	for at > last.start+1 && strings.TrimSpace(f.lines[at-1].text) == "" {
+		at--
+	}
+	f.insert(at, line{text: key + " = " + value, eol: f.eol})
+}
+
+// DeleteKey removes every occurrence of key (and its indented continuation
+// lines) from section.
+func (f *File) DeleteKey(section, key string) {
+	spans := f.spans(section)
+	for i := len(spans) - 1; i >= 0; i-- {
+		sp := spans[i]
+		for j := sp.end - 1; j > sp.start; j-- {
+			if k, _, ok := keyValue(f.lines[j].text); ok && k == key {
+				f.remove(j, j+1+f.continuation(j))
+			}
+		}
+	}
+}
+
+// DeleteSection removes every occurrence of section: its header, keys and
+// any blank lines up to the next section. Comment lines directly above the
+// next section's header describe that section and are kept.
+func (f *File) DeleteSection(section string) {
+	spans := f.spans(section)
+	for i := len(spans) - 1; i >= 0; i-- {
+		f.remove(spans[i].start, f.bodyEnd(spans[i]))
+	}
+}
+
+// bodyEnd returns the end of a section occurrence without the comment lines
+// directly above the next section's header, which describe that section.
+func (f *File) bodyEnd(sp span) int {
+	end := sp.end
+	if end < len(f.lines) {
+		for end > sp.start+1 && isComment(f.lines[end-1].text) {
+			end--
+		}
+	}
+	return end
+}
+
+// span is the half-open line range [start, end) of one section occurrence,
+// starting at its header.
+type span struct{ start, end int }
+
+// spans returns every occurrence of section in file order.
+func (f *File) spans(section string) []span {
+	var out []span
+	current := -1
+	for i, l := range f.lines {
+		name, ok := sectionName(l.text)
+		if !ok {
+			continue
+		}
+		if current >= 0 {
+			out = append(out, span{current, i})
+			current = -1
+		}
+		if name == section {
+			current = i
+		}
+	}
+	if current >= 0 {
+		out = append(out, span{current, len(f.lines)})
+	}
+	return out
+}
+
+// replaceKey rewrites the value of the key on line i, dropping any indented
+// continuation lines that belonged to the old value.
+func (f *File) replaceKey(i int, value string) {
+	text := f.lines[i].text
+	eq := strings.IndexByte(text, '=')
+	rest := text[eq+1:]
+	pad := rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+	if pad == "" && strings.HasSuffix(text[:eq], " ") {
+		pad = " "
+	}
+	f.lines[i].text = text[:eq+1] + pad + value
+	if n := f.continuation(i); n > 0 {
+		f.remove(i+1, i+1+n)
+	}
+}
+
+// continuation counts the indented, non-blank lines following line i, which
+// belong to a nested value such as "s3 =\n  max_concurrent_requests = 10".
+func (f *File) continuation(i int) int {
+	n := 0
+	for j := i + 1; j < len(f.lines); j++ {
+		text := f.lines[j].text
+		if strings.TrimSpace(text) == "" || (text[0] != ' ' && text[0] != '\t') {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// insert adds l before line at. Appending after an unterminated last line
+// moves the missing terminator to the new last line, so a file without a
+// final newline keeps lacking one.
+func (f *File) insert(at int, l line) {
+	if at > 0 && f.lines[at-1].eol == "" {
+		f.lines[at-1].eol = f.eol
+		l.eol = ""
+	}
+	f.lines = append(f.lines, line{})
+	copy(f.lines[at+1:], f.lines[at:])
+	f.lines[at] = l
+}
+
+func (f *File) remove(start, end int) {
+	f.lines = append(f.lines[:start], f.lines[end:]...)
+}
+
+// sectionName returns the name of a "[name]" header line.
+func sectionName(text string) (string, bool) {
+	t := strings.TrimSpace(strings.TrimPrefix(text, "\ufeff"))
+	if !strings.HasPrefix(t, "[") {
+		return "", false
+	}
+	end := strings.IndexByte(t, ']')
+	if end < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(t[1:end]), true
+}
+
+// isComment reports whether text is a full-line "#" or ";" comment.
+func isComment(text string) bool {
+	t := strings.TrimSpace(text)
+	return strings.HasPrefix(t, "#") || strings.HasPrefix(t, ";")
+}
+
+// keyValue parses an unindented "key = value" line. Comments, blank lines,
+// headers and indented continuation lines are not keys.
+func keyValue(text string) (string, string, bool) {
+	if text == "" || text[0] == ' ' || text[0] == '\t' || text[0] == '#' || text[0] == ';' || text[0] == '[' {
+		return "", "", false
+	}
+	eq := strings.IndexByte(text, '=')
+	if eq < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(text[:eq]), strings.TrimSpace(text[eq+1:]), true
+}
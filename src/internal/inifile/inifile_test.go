@@ -0,0 +1,109 @@
+package inifile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sample = `# managed by hand
+[default]
+region=us-east-1
+
+[profile dev]   ; inline note
+sso_session = corp
+sso_account_id = 111111111111
+s3 =
+  max_concurrent_requests = 10
+output = json
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+`
+
+func TestParse_RoundTrip(t *testing.T) {
+	for _, in := range []string{sample, "", "[a]\r\nk = v\r\n", "[a]\nk = v", "\ufeff[a]\nk=v\n"} {
+		assert.Equal(t, in, string(Parse([]byte(in)).Bytes()))
+	}
+}
+
+func TestGetAndKeys(t *testing.T) {
+	f := Parse([]byte(sample))
+	assert.Equal(t, []string{"default", "profile dev", "sso-session corp"}, f.Sections())
+	assert.True(t, f.HasSection("profile dev"))
+	assert.False(t, f.HasSection("profile prod"))
+
+	v, ok := f.Get("profile dev", "sso_account_id")
+	assert.True(t, ok)
+	assert.Equal(t, "111111111111", v)
+	_, ok = f.Get("profile dev", "max_concurrent_requests")
+	assert.False(t, ok, "nested values are not top-level keys")
+	assert.Equal(t, []string{"sso_session", "sso_account_id", "s3", "output"}, f.Keys("profile dev"))
+
+	dup := Parse([]byte("[a]\nk = 1\n[b]\n[a]\nk = 2\n"))
+	v, _ = dup.Get("a", "k")
+	assert.Equal(t, "2", v)
+}
+
+func TestSet(t *testing.T) {
+	f := Parse([]byte(sample))
+	f.Set("default", "region", "eu-west-1")
+	f.Set("profile dev", "sso_role_name", "Admin")
+	f.Set("profile dev", "s3", "disabled")
+	f.Set("profile prod", "sso_session", "corp")
+
+	assert.Equal(t, `# managed by hand
+[default]
+region=eu-west-1
+
+[profile dev]   ; inline note
+sso_session = corp
+sso_account_id = 111111111111
+s3 = disabled
+output = json
+sso_role_name = Admin
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+
+[profile prod]
+sso_session = corp
+`, string(f.Bytes()))
+}
+
+func TestSet_BeforeCommentsOfNextSection(t *testing.T) {
+	f := Parse([]byte("[a]\nk = v\n\n# about b\n[b]\n"))
+	f.Set("a", "x", "1")
+	assert.Equal(t, "[a]\nk = v\nx = 1\n\n# about b\n[b]\n", string(f.Bytes()))
+}
+
+func TestSet_PreservesLineEndings(t *testing.T) {
+	f := Parse([]byte("[a]\r\nk = v\r\n"))
+	f.Set("a", "x", "1")
+	f.Set("b", "y", "2")
+	assert.Equal(t, "[a]\r\nk = v\r\nx = 1\r\n\r\n[b]\r\ny = 2\r\n", string(f.Bytes()))
+
+	f = Parse([]byte("[a]\nk = v"))
+	f.Set("a", "x", "1")
+	assert.Equal(t, "[a]\nk = v\nx = 1", string(f.Bytes()))
+}
+
+func TestDelete(t *testing.T) {
+	f := Parse([]byte(sample))
+	f.DeleteKey("profile dev", "s3")
+	f.DeleteSection("default")
+	assert.Equal(t, `# managed by hand
+[profile dev]   ; inline note
+sso_session = corp
+sso_account_id = 111111111111
+output = json
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+`, string(f.Bytes()))
+
+	// A comment block directly above the next header belongs to that section.
+	f = Parse([]byte("[a]\nk = v\n# a trailer\n\n# about b\n; more\n[b]\nk = v\n\n[a]\nk = w\n"))
+	f.DeleteSection("a")
+	assert.Equal(t, "# about b\n; more\n[b]\nk = v\n\n", string(f.Bytes()))
+}
@@ -0,0 +1,88 @@
+// Package logging configures the logrus logger shared by every command: its
+// level, its text or JSON format, and the redaction of credentials (see
+// package redact) from messages and fields before anything is written.
+package logging
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/witnsby/aws-sso-login/src/internal/redact"
+)
+
+// Supported log formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Configure sets the level and format of logger. level is any name accepted
+// by logrus.ParseLevel (e.g. debug, info, warn, error); format is FormatText
+// or FormatJSON. Output is always passed through redaction.
+func Configure(logger *logrus.Logger, level, format string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	var formatter logrus.Formatter
+	switch strings.ToLower(format) {
+	case FormatText:
+		formatter = &logrus.TextFormatter{}
+	case FormatJSON:
+		formatter = &logrus.JSONFormatter{}
+	default:
+		return fmt.Errorf("invalid log format %q: expected %s or %s", format, FormatText, FormatJSON)
+	}
+
+	logger.SetLevel(lvl)
+	logger.SetFormatter(&redactingFormatter{next: formatter})
+	return nil
+}
+
+// redactingFormatter strips secrets from an entry's message and fields and
+// hands the cleaned copy to the wrapped formatter.
+type redactingFormatter struct {
+	next logrus.Formatter
+}
+
+func (f *redactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	clean := *entry
+	clean.Message = redact.String(entry.Message)
+	clean.Data = make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		clean.Data[key] = redactField(key, value)
+	}
+	return f.next.Format(&clean)
+}
+
+// redactField hides the whole value of fields whose name marks a secret and
+// scrubs secrets embedded in any other text value.
+func redactField(key string, value any) any {
+	if isSecretName(key) {
+		return redact.Placeholder
+	}
+	switch v := value.(type) {
+	case string:
+		return redact.String(v)
+	case error:
+		return redact.String(v.Error())
+	case fmt.Stringer:
+		return redact.String(v.String())
+	default:
+		return value
+	}
+}
+
+// isSecretName reports whether a field name such as "session_token",
+// "SecretAccessKey" or "signin-token" names a credential.
+func isSecretName(key string) bool {
+	name := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	for _, word := range []string{"secret", "token", "password", "accesskeyid", "sessionkey"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
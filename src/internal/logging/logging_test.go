@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/witnsby/aws-sso-login/src/internal/redact"
+)
+
+const (
+	accessKeyID  = "ASIAEXAMPLEEXAMPLE12"
+	secretKey    = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	sessionToken = "IQoJb3JpZ2luX2VjEXAMPLETOKEN=="
+	signinToken  = "SigninTokenValueEXAMPLE"
+)
+
+func newLogger(t *testing.T, level, format string) (*logrus.Logger, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	require.NoError(t, Configure(logger, level, format))
+	return logger, &buf
+}
+
+func TestConfigure_Invalid(t *testing.T) {
+	assert.ErrorContains(t, Configure(logrus.New(), "chatty", FormatText), `invalid log level "chatty"`)
+	assert.ErrorContains(t, Configure(logrus.New(), "info", "xml"), `invalid log format "xml"`)
+}
+
+func TestConfigure_Level(t *testing.T) {
+	logger, buf := newLogger(t, "warn", FormatText)
+	logger.Info("hidden")
+	logger.Warn("shown")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "shown")
+}
+
+func TestConfigure_JSON(t *testing.T) {
+	logger, buf := newLogger(t, "debug", "JSON")
+	logger.WithField("profile", "dev").Debug("hello")
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "hello", line["msg"])
+	assert.Equal(t, "debug", line["level"])
+	assert.Equal(t, "dev", line["profile"])
+}
+
+func TestRedaction(t *testing.T) {
+	for _, format := range []string{FormatText, FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			logger, buf := newLogger(t, "debug", format)
+			logger.WithFields(logrus.Fields{
+				"session_token":   sessionToken,
+				"SecretAccessKey": secretKey,
+				"url":             "https://signin.aws.amazon.com/federation?Action=login&SigninToken=" + signinToken,
+				"federation":      "https://signin.aws.amazon.com/federation?Action=getSigninToken&Session=%7B%22sessionId%22%3A%22x%22%7D",
+				"cause":           errors.New("aws_secret_access_key = " + secretKey),
+				"profile":         "dev",
+			}).Infof("using %s with {\"sessionToken\":\"%s\"}", accessKeyID, sessionToken)
+
+			out := buf.String()
+			for _, secret := range []string{accessKeyID, secretKey, sessionToken, signinToken, "sessionId"} {
+				assert.NotContains(t, out, secret)
+			}
+			assert.Contains(t, out, "ASIA"+redact.Placeholder)
+			assert.Contains(t, out, "dev")
+		})
+	}
+}
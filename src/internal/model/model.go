@@ -2,7 +2,7 @@ package model
 
 // RoleCredential replicates the structure of the JSON from the cache file
 type RoleCredential struct {
-	Version         int    `json:"Version"`
+	Version         int    `json:"Version,omitempty"`
 	AccessKeyId     string `json:"AccessKeyId"`
 	SecretAccessKey string `json:"SecretAccessKey"`
 	SessionToken    string `json:"SessionToken"`
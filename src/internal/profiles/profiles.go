@@ -8,6 +8,10 @@ import (
 	"github.com/go-ini/ini"
 )
 
+// ssoSessionPrefix is the section name prefix of token-provider sessions,
+// e.g. [sso-session corp].
+const ssoSessionPrefix = "sso-session "
+
 // Profile holds the SSO-relevant fields for a single AWS named profile.
 type Profile struct {
 	Name      string // e.g. "dev-account"
@@ -15,10 +19,18 @@ type Profile struct {
 	RoleName  string // sso_role_name
 	Region    string // sso_region
 	StartURL  string // sso_start_url
+	Session   string // sso_session, empty for legacy profiles
+	// SourceProfile is the source_profile of a chained role_arn profile,
+	// empty for SSO profiles. AccountID and RoleName then come from role_arn.
+	SourceProfile string
 }
 
 // ListSSOProfiles parses configPath (e.g. ~/.aws/config), returns only
 // profiles that have a non-empty sso_start_url, sorted alphabetically by Name.
+// Profiles using sso_session are resolved against their [sso-session <name>]
+// section first; profiles whose session cannot be resolved are skipped.
+// Chained role_arn profiles are included when their source_profile chain
+// ends in an SSO profile.
 // Default profile ([default]) is included if it is SSO-enabled.
 func ListSSOProfiles(configPath string) ([]Profile, error) {
 	cfg, err := ini.LoadSources(ini.LoadOptions{}, configPath)
@@ -33,17 +45,32 @@ func ListSSOProfiles(configPath string) ([]Profile, error) {
 			continue
 		}
 
+		accountID := section.Key("sso_account_id").String()
+		roleName := section.Key("sso_role_name").String()
+		if roleARN := section.Key("role_arn").String(); roleARN != "" {
+			if _, err := ResolveSourceProfile(cfg, section); err != nil {
+				continue
+			}
+			if accountID, roleName, err = ParseRoleARN(roleARN); err != nil {
+				continue
+			}
+		} else if err := ResolveSSOSession(cfg, section); err != nil {
+			continue
+		}
+
 		startURL := section.Key("sso_start_url").String()
 		if startURL == "" {
 			continue
 		}
 
 		results = append(results, Profile{
-			Name:      name,
-			AccountID: section.Key("sso_account_id").String(),
-			RoleName:  section.Key("sso_role_name").String(),
-			Region:    section.Key("sso_region").String(),
-			StartURL:  startURL,
+			Name:          name,
+			AccountID:     accountID,
+			RoleName:      roleName,
+			Region:        section.Key("sso_region").String(),
+			StartURL:      startURL,
+			Session:       section.Key("sso_session").String(),
+			SourceProfile: section.Key("source_profile").String(),
 		})
 	}
 
@@ -58,6 +85,113 @@ func ListSSOProfiles(configPath string) ([]Profile, error) {
 	return results, nil
 }
 
+// ResolveSSOSession copies sso_start_url, sso_region and (when set)
+// sso_registration_scopes from the
+// [sso-session <name>] section referenced by the profile's sso_session key
+// into the profile section itself, so callers can read them uniformly for
+// both the legacy and the token-provider configuration layouts.
+//
+// Sections without sso_session are left untouched. A missing session section,
+// a session without sso_start_url/sso_region, or a profile value that
+// conflicts with the session value is reported as an error, mirroring the
+// AWS CLI v2 behaviour.
+func ResolveSSOSession(cfg *ini.File, section *ini.Section) error {
+	sessionName := section.Key("sso_session").String()
+	if sessionName == "" {
+		return nil
+	}
+
+	session, err := cfg.GetSection(ssoSessionPrefix + sessionName)
+	if err != nil {
+		return fmt.Errorf("sso-session %q referenced by [%s] not found", sessionName, section.Name())
+	}
+
+	for _, key := range []string{"sso_start_url", "sso_region"} {
+		sessionValue := session.Key(key).String()
+		if sessionValue == "" {
+			return fmt.Errorf("missing required attribute %q in sso-session %s", key, sessionName)
+		}
+		profileValue := section.Key(key).String()
+		if profileValue != "" && profileValue != sessionValue {
+			return fmt.Errorf("%s in [%s] (%s) does not match sso-session %s (%s)",
+				key, section.Name(), profileValue, sessionName, sessionValue)
+		}
+		section.Key(key).SetValue(sessionValue)
+	}
+	if scopes := session.Key("sso_registration_scopes").String(); scopes != "" {
+		section.Key("sso_registration_scopes").SetValue(scopes)
+	}
+	return nil
+}
+
+// ResolveSourceProfile follows the source_profile chain of a role_arn profile
+// down to the SSO profile at its base, resolves the base's sso-session and
+// copies its SSO connection settings (sso_start_url, sso_region, sso_session
+// and sso_registration_scopes) into section, so a chained profile logs in and
+// finds its SSO token exactly like its base profile.
+//
+// The base section is returned. A missing source_profile, an unknown profile,
+// a cycle, a hop using credential_source, or a base without SSO settings is
+// reported as an error.
+func ResolveSourceProfile(cfg *ini.File, section *ini.Section) (*ini.Section, error) {
+	visited := map[string]bool{section.Name(): true}
+	current := section
+	for current.Key("role_arn").String() != "" {
+		if current.Key("credential_source").String() != "" {
+			return nil, fmt.Errorf("[%s] uses credential_source, which is not supported; use source_profile", current.Name())
+		}
+		source := current.Key("source_profile").String()
+		if source == "" {
+			return nil, fmt.Errorf("[%s] has role_arn but no source_profile", current.Name())
+		}
+		next, err := cfg.GetSection(sectionName(source))
+		if err != nil {
+			return nil, fmt.Errorf("source_profile %q referenced by [%s] not found", source, current.Name())
+		}
+		if visited[next.Name()] {
+			return nil, fmt.Errorf("source_profile chain of [%s] loops back to [%s]", section.Name(), next.Name())
+		}
+		visited[next.Name()] = true
+		current = next
+	}
+
+	if err := ResolveSSOSession(cfg, current); err != nil {
+		return nil, err
+	}
+	if current.Key("sso_start_url").String() == "" {
+		return nil, fmt.Errorf("source profile [%s] of [%s] is not an SSO profile", current.Name(), section.Name())
+	}
+	for _, key := range []string{"sso_start_url", "sso_region", "sso_session", "sso_registration_scopes"} {
+		if value := current.Key(key).String(); value != "" {
+			section.Key(key).SetValue(value)
+		}
+	}
+	return current, nil
+}
+
+// ParseRoleARN returns the account ID and role name of an IAM role ARN such
+// as arn:aws:iam::123456789012:role/path/Name.
+func ParseRoleARN(arn string) (accountID, roleName string, err error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "iam" || parts[4] == "" || !strings.HasPrefix(parts[5], "role/") {
+		return "", "", fmt.Errorf("invalid role ARN %q", arn)
+	}
+	path := strings.TrimPrefix(parts[5], "role/")
+	roleName = path[strings.LastIndex(path, "/")+1:]
+	if roleName == "" {
+		return "", "", fmt.Errorf("invalid role ARN %q", arn)
+	}
+	return parts[4], roleName, nil
+}
+
+// sectionName returns the config section name of a profile.
+func sectionName(profile string) string {
+	if profile == "default" {
+		return "default"
+	}
+	return "profile " + profile
+}
+
 // profileName returns the logical profile name and true when the section is a
 // recognised AWS config section ([default] or [profile <name>]).
 // Returns "", false for all other sections (e.g. the synthetic DEFAULT section
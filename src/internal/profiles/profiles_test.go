@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/go-ini/ini"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -145,3 +146,217 @@ sso_region = us-east-1
 	assert.Equal(t, "Beta", profiles[1].Name)
 	assert.Equal(t, "Zulu", profiles[2].Name)
 }
+
+func TestListSSOProfiles_SSOSession(t *testing.T) {
+	t.Parallel()
+	path := writeConfig(t, `
+[profile session-based]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Admin
+
+[profile dangling]
+sso_session = missing
+sso_account_id = 123456789012
+sso_role_name = Admin
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+sso_region = eu-central-1
+sso_registration_scopes = sso:account:access
+`)
+	profiles, err := ListSSOProfiles(path)
+	require.NoError(t, err)
+	require.Len(t, profiles, 1)
+
+	assert.Equal(t, "session-based", profiles[0].Name)
+	assert.Equal(t, "https://example.awsapps.com/start", profiles[0].StartURL)
+	assert.Equal(t, "eu-central-1", profiles[0].Region)
+	assert.Equal(t, "corp", profiles[0].Session)
+}
+
+func TestResolveSSOSession(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		config  string
+		wantErr string
+		wantURL string
+	}{
+		{
+			name: "legacy profile untouched",
+			config: `
+[profile p]
+sso_start_url = https://legacy.awsapps.com/start
+`,
+			wantURL: "https://legacy.awsapps.com/start",
+		},
+		{
+			name: "values copied from session",
+			config: `
+[profile p]
+sso_session = corp
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+`,
+			wantURL: "https://example.awsapps.com/start",
+		},
+		{
+			name: "missing session section",
+			config: `
+[profile p]
+sso_session = corp
+`,
+			wantErr: `sso-session "corp" referenced by [profile p] not found`,
+		},
+		{
+			name: "session without region",
+			config: `
+[profile p]
+sso_session = corp
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+`,
+			wantErr: `missing required attribute "sso_region"`,
+		},
+		{
+			name: "conflicting start url",
+			config: `
+[profile p]
+sso_session = corp
+sso_start_url = https://other.awsapps.com/start
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+`,
+			wantErr: "does not match sso-session corp",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfg, err := ini.Load([]byte(tc.config))
+			require.NoError(t, err)
+			section, err := cfg.GetSection("profile p")
+			require.NoError(t, err)
+
+			err = ResolveSSOSession(cfg, section)
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantURL, section.Key("sso_start_url").String())
+		})
+	}
+}
+
+const chainedConfig = `
+[profile base]
+sso_session = corp
+sso_account_id = 111111111111
+sso_role_name = Admin
+
+[profile middle]
+role_arn = arn:aws:iam::222222222222:role/Hop
+source_profile = base
+
+[profile deploy]
+role_arn = arn:aws:iam::333333333333:role/team/Deploy
+source_profile = middle
+region = eu-west-1
+
+[profile loop-a]
+role_arn = arn:aws:iam::333333333333:role/A
+source_profile = loop-b
+
+[profile loop-b]
+role_arn = arn:aws:iam::333333333333:role/B
+source_profile = loop-a
+
+[profile orphan]
+role_arn = arn:aws:iam::333333333333:role/Orphan
+source_profile = missing
+
+[profile static]
+aws_access_key_id = AKIAEXAMPLE
+
+[profile from-static]
+role_arn = arn:aws:iam::333333333333:role/Static
+source_profile = static
+
+[profile instance]
+role_arn = arn:aws:iam::333333333333:role/Instance
+credential_source = Ec2InstanceMetadata
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+sso_region = eu-central-1
+`
+
+func TestResolveSourceProfile(t *testing.T) {
+	t.Parallel()
+	cfg, err := ini.Load([]byte(chainedConfig))
+	require.NoError(t, err)
+
+	deploy := cfg.Section("profile deploy")
+	base, err := ResolveSourceProfile(cfg, deploy)
+	require.NoError(t, err)
+	assert.Equal(t, "profile base", base.Name())
+	assert.Equal(t, "https://example.awsapps.com/start", deploy.Key("sso_start_url").String())
+	assert.Equal(t, "eu-central-1", deploy.Key("sso_region").String())
+	assert.Equal(t, "corp", deploy.Key("sso_session").String())
+	assert.Equal(t, "eu-west-1", deploy.Key("region").String())
+	assert.False(t, deploy.HasKey("sso_account_id"), "account and role must come from role_arn")
+
+	cases := map[string]string{
+		"loop-a":      "source_profile chain of [profile loop-a] loops back to [profile loop-a]",
+		"orphan":      `source_profile "missing" referenced by [profile orphan] not found`,
+		"from-static": "source profile [profile static] of [profile from-static] is not an SSO profile",
+		"instance":    "[profile instance] uses credential_source, which is not supported",
+	}
+	for name, want := range cases {
+		_, err := ResolveSourceProfile(cfg, cfg.Section("profile "+name))
+		assert.ErrorContains(t, err, want, name)
+	}
+}
+
+func TestListSSOProfiles_Chained(t *testing.T) {
+	t.Parallel()
+	profiles, err := ListSSOProfiles(writeConfig(t, chainedConfig))
+	require.NoError(t, err)
+
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	assert.Equal(t, []string{"base", "deploy", "middle"}, names)
+	assert.Equal(t, Profile{
+		Name:          "deploy",
+		AccountID:     "333333333333",
+		RoleName:      "Deploy",
+		Region:        "eu-central-1",
+		StartURL:      "https://example.awsapps.com/start",
+		Session:       "corp",
+		SourceProfile: "middle",
+	}, profiles[1])
+}
+
+func TestParseRoleARN(t *testing.T) {
+	t.Parallel()
+	account, role, err := ParseRoleARN("arn:aws-us-gov:iam::123456789012:role/path/to/Deploy")
+	require.NoError(t, err)
+	assert.Equal(t, "123456789012", account)
+	assert.Equal(t, "Deploy", role)
+
+	for _, bad := range []string{"", "Deploy", "arn:aws:iam::123456789012:user/bob", "arn:aws:iam:::role/Deploy", "arn:aws:iam::123456789012:role/"} {
+		_, _, err := ParseRoleARN(bad)
+		assert.Error(t, err, bad)
+	}
+}
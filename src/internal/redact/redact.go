@@ -0,0 +1,118 @@
+// Package redact removes AWS credentials, SSO tokens and console sign-in
+// tokens from text before it is logged, wrapped into an error or printed.
+//
+// Secrets are recognised two ways: by shape (access key IDs, "name=value"
+// pairs whose name marks a credential, the federation Session parameter) and
+// by value, for credentials the tool has handled and registered with
+// Register. Output meant to carry credentials, such as export and process,
+// must not be passed through this package.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces secret values.
+const Placeholder = "[REDACTED]"
+
+// minSecretLength keeps short registered values, which are unlikely to be
+// real credentials, from blanking out ordinary words.
+const minSecretLength = 8
+
+var (
+	// accessKeyIDPattern matches long-term (AKIA) and temporary (ASIA) access key IDs.
+	accessKeyIDPattern = regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`)
+	// assignmentPattern matches "name=value", "name: value" and JSON
+	// "name": "value" pairs whose name marks a credential, including the
+	// federation endpoint's SigninToken query parameter.
+	assignmentPattern = regexp.MustCompile(`(?i)\b(aws_secret_access_key|aws_session_token|aws_security_token|` +
+		`secret_?access_?key|session_?token|session_?key|signin_?token|access_?token|refresh_?token|bearer_?token|client_?secret)` +
+		`("?\s*[:=]\s*"?)([^\s"'&,;:)\]}]+)`)
+	// sessionParamPattern matches the federation endpoint's Session query
+	// parameter, which carries the URL-encoded credentials.
+	sessionParamPattern = regexp.MustCompile(`([?&]Session=)[^&\s"]+`)
+)
+
+var registry = struct {
+	sync.RWMutex
+	secrets map[string]bool
+}{secrets: map[string]bool{}}
+
+// Register marks values as secret, so String replaces them wherever they
+// appear. Empty and very short values are ignored.
+func Register(values ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, v := range values {
+		if len(v) >= minSecretLength {
+			registry.secrets[v] = true
+		}
+	}
+}
+
+// String returns s with registered secrets, access key IDs, the values of
+// credential assignments and federation Session parameters replaced by
+// Placeholder. Access key IDs keep their AKIA/ASIA prefix.
+func String(s string) string {
+	s = replaceRegistered(s)
+	s = accessKeyIDPattern.ReplaceAllString(s, "${1}"+Placeholder)
+	s = sessionParamPattern.ReplaceAllString(s, "${1}"+Placeholder)
+	return assignmentPattern.ReplaceAllString(s, "${1}${2}"+Placeholder)
+}
+
+// replaceRegistered replaces registered secrets, longest first so a secret
+// containing another is replaced whole.
+func replaceRegistered(s string) string {
+	registry.RLock()
+	secrets := make([]string, 0, len(registry.secrets))
+	for v := range registry.secrets {
+		if strings.Contains(s, v) {
+			secrets = append(secrets, v)
+		}
+	}
+	registry.RUnlock()
+
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, v := range secrets {
+		s = strings.ReplaceAll(s, v, Placeholder)
+	}
+	return s
+}
+
+// Error returns err with a redacted message. The original error stays in
+// the chain, so errors.Is and errors.As keep working. A nil err or one
+// without secrets is returned unchanged.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	clean := String(msg)
+	if clean == msg {
+		return err
+	}
+	return &redactedError{msg: clean, err: err}
+}
+
+// Errorf is fmt.Errorf followed by Error.
+func Errorf(format string, args ...any) error {
+	return Error(fmt.Errorf(format, args...))
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
+
+// ContainsSecret reports whether s contains anything String would redact.
+func ContainsSecret(s string) bool {
+	return String(s) != s
+}
@@ -0,0 +1,90 @@
+package sso
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+)
+
+// CachedToken mirrors the JSON the AWS CLI v2 writes to ~/.aws/sso/cache, so
+// tokens obtained by either tool can be used by the other.
+type CachedToken struct {
+	StartURL              string `json:"startUrl"`
+	Region                string `json:"region"`
+	AccessToken           string `json:"accessToken"`
+	ExpiresAt             string `json:"expiresAt"`
+	ClientID              string `json:"clientId,omitempty"`
+	ClientSecret          string `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt string `json:"registrationExpiresAt,omitempty"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
+}
+
+// TimeFormat is the UTC timestamp layout the AWS CLI uses in its cache files.
+const TimeFormat = "2006-01-02T15:04:05Z"
+
+// TokenCacheKey returns the cache file base name for a token: the SHA-1 of the
+// sso-session name when one is configured, otherwise of the start URL.
+func TokenCacheKey(sessionName, startURL string) string {
+	input := startURL
+	if sessionName != "" {
+		input = sessionName
+	}
+	return fmt.Sprintf("%x", sha1.Sum([]byte(input)))
+}
+
+// LoadToken reads the cached token stored under key in dir.
+func LoadToken(dir, key string) (*CachedToken, error) {
+	data, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var tok CachedToken
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return nil, fmt.Errorf("decoding SSO token cache: %w", err)
+	}
+	return &tok, nil
+}
+
+// SaveToken writes tok under key in dir with owner-only permissions.
+func SaveToken(dir, key string, tok *CachedToken) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, key+".json"), data, 0o600)
+}
+
+// Valid reports whether the access token is present and not yet expired.
+func (t *CachedToken) Valid(now time.Time) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	expiresAt, ok := parseTime(t.ExpiresAt)
+	return ok && now.Before(expiresAt)
+}
+
+// RegistrationValid reports whether the cached client registration can be reused.
+func (t *CachedToken) RegistrationValid(now time.Time) bool {
+	if t == nil || t.ClientID == "" || t.ClientSecret == "" {
+		return false
+	}
+	expiresAt, ok := parseTime(t.RegistrationExpiresAt)
+	return ok && now.Before(expiresAt)
+}
+
+func parseTime(value string) (time.Time, bool) {
+	for _, layout := range append([]string{time.RFC3339}, helper.TimeLayouts...) {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
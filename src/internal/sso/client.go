@@ -0,0 +1,325 @@
+package sso
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+)
+
+// Client talks to the AWS SSO OIDC and SSO portal APIs over plain HTTPS.
+// The endpoint fields are base URLs without a trailing slash and may be
+// pointed at a local fake server in tests.
+type Client struct {
+	OIDCEndpoint   string
+	PortalEndpoint string
+	HTTPClient     *http.Client
+}
+
+// NewClient returns a Client for the given SSO region, using the DNS suffix
+// of the region's partition. The endpoints honour the standard
+// AWS_ENDPOINT_URL_SSO_OIDC and AWS_ENDPOINT_URL_SSO overrides.
+func NewClient(region string) *Client {
+	suffix := helper.PartitionForRegion(region).DNSSuffix
+	oidc := fmt.Sprintf("https://oidc.%s.%s", region, suffix)
+	if v := os.Getenv("AWS_ENDPOINT_URL_SSO_OIDC"); v != "" {
+		oidc = v
+	}
+	portal := fmt.Sprintf("https://portal.sso.%s.%s", region, suffix)
+	if v := os.Getenv("AWS_ENDPOINT_URL_SSO"); v != "" {
+		portal = v
+	}
+	return &Client{
+		OIDCEndpoint:   strings.TrimSuffix(oidc, "/"),
+		PortalEndpoint: strings.TrimSuffix(portal, "/"),
+		HTTPClient:     http.DefaultClient,
+	}
+}
+
+// APIError is returned for any non-2xx response from the OIDC or portal API.
+type APIError struct {
+	StatusCode int
+	Code       string // e.g. "ForbiddenException" or "authorization_pending"
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s (HTTP %d)", e.Code, e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s (HTTP %d)", e.Code, e.Message, e.StatusCode)
+}
+
+// RegisterClientOutput holds a public OIDC client registration.
+type RegisterClientOutput struct {
+	ClientID              string `json:"clientId"`
+	ClientSecret          string `json:"clientSecret"`
+	ClientIDIssuedAt      int64  `json:"clientIdIssuedAt"`
+	ClientSecretExpiresAt int64  `json:"clientSecretExpiresAt"`
+}
+
+// DeviceAuthorization holds the codes returned by StartDeviceAuthorization.
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"deviceCode"`
+	UserCode                string `json:"userCode"`
+	VerificationURI         string `json:"verificationUri"`
+	VerificationURIComplete string `json:"verificationUriComplete"`
+	ExpiresIn               int    `json:"expiresIn"`
+	Interval                int    `json:"interval"`
+}
+
+// Token is the access token returned by CreateToken.
+type Token struct {
+	AccessToken  string `json:"accessToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int    `json:"expiresIn"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// RoleCredentials are the short-term credentials returned by GetRoleCredentials.
+// Expiration is in milliseconds since the Unix epoch.
+type RoleCredentials struct {
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	SessionToken    string `json:"sessionToken"`
+	Expiration      int64  `json:"expiration"`
+}
+
+// RegisterClient registers a public OIDC client used for the device flow.
+func (c *Client) RegisterClient(ctx context.Context, clientName string, scopes []string) (*RegisterClientOutput, error) {
+	in := map[string]any{
+		"clientName": clientName,
+		"clientType": "public",
+	}
+	if len(scopes) > 0 {
+		in["scopes"] = scopes
+	}
+	var out RegisterClientOutput
+	if err := c.postJSON(ctx, c.OIDCEndpoint+"/client/register", in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StartDeviceAuthorization starts the device authorization flow for startURL.
+func (c *Client) StartDeviceAuthorization(ctx context.Context, clientID, clientSecret, startURL string) (*DeviceAuthorization, error) {
+	in := map[string]string{
+		"clientId":     clientID,
+		"clientSecret": clientSecret,
+		"startUrl":     startURL,
+	}
+	var out DeviceAuthorization
+	if err := c.postJSON(ctx, c.OIDCEndpoint+"/device_authorization", in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateToken exchanges a device code for an access token. While the user has
+// not yet approved the request the returned error is an *APIError with Code
+// "authorization_pending".
+func (c *Client) CreateToken(ctx context.Context, clientID, clientSecret, deviceCode string) (*Token, error) {
+	in := map[string]string{
+		"clientId":     clientID,
+		"clientSecret": clientSecret,
+		"grantType":    "urn:ietf:params:oauth:grant-type:device_code",
+		"deviceCode":   deviceCode,
+	}
+	var out Token
+	if err := c.postJSON(ctx, c.OIDCEndpoint+"/token", in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRoleCredentials returns short-term credentials for the given account and
+// role using an SSO access token.
+func (c *Client) GetRoleCredentials(ctx context.Context, accessToken, accountID, roleName string) (*RoleCredentials, error) {
+	q := url.Values{}
+	q.Set("account_id", accountID)
+	q.Set("role_name", roleName)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.PortalEndpoint+"/federation/credentials?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-amz-sso_bearer_token", accessToken)
+
+	var out struct {
+		RoleCredentials RoleCredentials `json:"roleCredentials"`
+	}
+	if err := c.do(req, &out); err != nil {
+		return nil, err
+	}
+	return &out.RoleCredentials, nil
+}
+
+// Account is an AWS account the SSO user has access to.
+type Account struct {
+	AccountID    string `json:"accountId"`
+	AccountName  string `json:"accountName"`
+	EmailAddress string `json:"emailAddress"`
+}
+
+// AccountRole is a role the SSO user may assume in an account.
+type AccountRole struct {
+	AccountID string `json:"accountId"`
+	RoleName  string `json:"roleName"`
+}
+
+// listPageSize is the max_result requested from the paginated portal APIs.
+const listPageSize = 100
+
+// ListAccounts calls the SSO portal ListAccounts API and returns every
+// account assigned to the user, following pagination.
+func (c *Client) ListAccounts(ctx context.Context, accessToken string) ([]Account, error) {
+	var accounts []Account
+	err := c.paginate(ctx, accessToken, "/assignment/accounts", url.Values{}, func(data []byte) (string, error) {
+		var page struct {
+			AccountList []Account `json:"accountList"`
+			NextToken   string    `json:"nextToken"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return "", err
+		}
+		accounts = append(accounts, page.AccountList...)
+		return page.NextToken, nil
+	})
+	return accounts, err
+}
+
+// ListAccountRoles calls the SSO portal ListAccountRoles API and returns
+// every role the user may assume in accountID, following pagination.
+func (c *Client) ListAccountRoles(ctx context.Context, accessToken, accountID string) ([]AccountRole, error) {
+	var roles []AccountRole
+	q := url.Values{}
+	q.Set("account_id", accountID)
+	err := c.paginate(ctx, accessToken, "/assignment/roles", q, func(data []byte) (string, error) {
+		var page struct {
+			RoleList  []AccountRole `json:"roleList"`
+			NextToken string        `json:"nextToken"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return "", err
+		}
+		roles = append(roles, page.RoleList...)
+		return page.NextToken, nil
+	})
+	return roles, err
+}
+
+// paginate issues GET requests to a paginated portal API until handle
+// returns an empty next token.
+func (c *Client) paginate(ctx context.Context, accessToken, path string, q url.Values, handle func([]byte) (string, error)) error {
+	q.Set("max_result", fmt.Sprint(listPageSize))
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.PortalEndpoint+path+"?"+q.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("x-amz-sso_bearer_token", accessToken)
+
+		var page json.RawMessage
+		if err := c.do(req, &page); err != nil {
+			return err
+		}
+		next, err := handle(page)
+		if err != nil {
+			return fmt.Errorf("decoding %s response: %w", path, err)
+		}
+		if next == "" {
+			return nil
+		}
+		q.Set("next_token", next)
+	}
+}
+
+// postJSON sends in as a JSON body to endpoint and decodes the response into out.
+func (c *Client) postJSON(ctx context.Context, endpoint string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, out)
+}
+
+// do executes req and decodes a successful JSON response into out, or turns
+// an error response into an *APIError.
+func (c *Client) do(req *http.Request, out any) error {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling %s: %w", req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading %s response: %w", req.URL.Path, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return parseAPIError(resp, data)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding %s response: %w", req.URL.Path, err)
+	}
+	return nil
+}
+
+// parseAPIError extracts the error code from either the OAuth-style body used
+// by SSO OIDC ({"error": ...}) or the AWS REST-JSON style used by the portal
+// (x-amzn-ErrorType header / {"__type": ...}).
+func parseAPIError(resp *http.Response, data []byte) *APIError {
+	var body struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		Type             string `json:"__type"`
+		Message          string `json:"message"`
+		MessageUpper     string `json:"Message"`
+	}
+	_ = json.Unmarshal(data, &body)
+
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	switch {
+	case resp.Header.Get("x-amzn-ErrorType") != "":
+		apiErr.Code = resp.Header.Get("x-amzn-ErrorType")
+	case body.Type != "":
+		apiErr.Code = body.Type
+	default:
+		apiErr.Code = body.Error
+	}
+	// Codes may carry a namespace prefix or a ":<url>" suffix.
+	if i := strings.Index(apiErr.Code, ":"); i >= 0 {
+		apiErr.Code = apiErr.Code[:i]
+	}
+	if i := strings.LastIndex(apiErr.Code, "#"); i >= 0 {
+		apiErr.Code = apiErr.Code[i+1:]
+	}
+	if apiErr.Code == "" {
+		apiErr.Code = http.StatusText(resp.StatusCode)
+	}
+
+	for _, msg := range []string{body.Message, body.MessageUpper, body.ErrorDescription} {
+		if msg != "" {
+			apiErr.Message = msg
+			break
+		}
+	}
+	return apiErr
+}
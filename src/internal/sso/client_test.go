@@ -0,0 +1,264 @@
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSSO is a minimal stand-in for the SSO OIDC and portal APIs.
+type fakeSSO struct {
+	pendingPolls int // number of authorization_pending replies before success
+	polls        int
+}
+
+func (f *fakeSSO) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/client/register", func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, "public", in["clientType"])
+		writeJSON(w, http.StatusOK, map[string]any{
+			"clientId":              "client-id",
+			"clientSecret":          "client-secret",
+			"clientSecretExpiresAt": time.Now().Add(90 * 24 * time.Hour).Unix(),
+		})
+	})
+	mux.HandleFunc("/device_authorization", func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, "https://example.awsapps.com/start", in["startUrl"])
+		writeJSON(w, http.StatusOK, map[string]any{
+			"deviceCode":              "device-code",
+			"userCode":                "ABCD-EFGH",
+			"verificationUri":         "https://device.sso.us-east-1.amazonaws.com/",
+			"verificationUriComplete": "https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH",
+			"expiresIn":               600,
+			"interval":                1,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, "device-code", in["deviceCode"])
+		f.polls++
+		if f.polls <= f.pendingPolls {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"accessToken": "access-token",
+			"tokenType":   "Bearer",
+			"expiresIn":   28800,
+		})
+	})
+	mux.HandleFunc("/federation/credentials", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-amz-sso_bearer_token") != "access-token" {
+			w.Header().Set("x-amzn-ErrorType", "UnauthorizedException:http://internal.amazon.com/coral/com.amazonaws.switchboard.portal/")
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Session token not found or invalid"})
+			return
+		}
+		if r.URL.Query().Get("role_name") != "Admin" {
+			writeJSON(w, http.StatusForbidden, map[string]string{"__type": "com.amazonaws.switchboard.portal#ForbiddenException", "message": "No access"})
+			return
+		}
+		assert.Equal(t, "123456789012", r.URL.Query().Get("account_id"))
+		writeJSON(w, http.StatusOK, map[string]any{
+			"roleCredentials": map[string]any{
+				"accessKeyId":     "ASIAEXAMPLE",
+				"secretAccessKey": "secret",
+				"sessionToken":    "session",
+				"expiration":      int64(1700000000000),
+			},
+		})
+	})
+	mux.HandleFunc("/assignment/accounts", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "access-token", r.Header.Get("x-amz-sso_bearer_token"))
+		if r.URL.Query().Get("next_token") == "" {
+			writeJSON(w, http.StatusOK, map[string]any{
+				"accountList": []map[string]string{{"accountId": "111111111111", "accountName": "dev"}},
+				"nextToken":   "page-2",
+			})
+			return
+		}
+		assert.Equal(t, "page-2", r.URL.Query().Get("next_token"))
+		writeJSON(w, http.StatusOK, map[string]any{
+			"accountList": []map[string]string{{"accountId": "222222222222", "accountName": "prod"}},
+		})
+	})
+	mux.HandleFunc("/assignment/roles", func(w http.ResponseWriter, r *http.Request) {
+		accountID := r.URL.Query().Get("account_id")
+		writeJSON(w, http.StatusOK, map[string]any{
+			"roleList": []map[string]string{
+				{"accountId": accountID, "roleName": "Admin"},
+				{"accountId": accountID, "roleName": "ReadOnly"},
+			},
+		})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newTestClient(t *testing.T, f *fakeSSO) *Client {
+	t.Helper()
+	srv := httptest.NewServer(f.handler(t))
+	t.Cleanup(srv.Close)
+	return &Client{OIDCEndpoint: srv.URL, PortalEndpoint: srv.URL, HTTPClient: srv.Client()}
+}
+
+// noSleep replaces sleepFunc for the duration of the test and records waits.
+func noSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	orig := sleepFunc
+	t.Cleanup(func() { sleepFunc = orig })
+	sleepFunc = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return &waits
+}
+
+func TestDeviceAuthorizationFlow(t *testing.T) {
+	waits := noSleep(t)
+	f := &fakeSSO{pendingPolls: 2}
+	c := newTestClient(t, f)
+	ctx := context.Background()
+
+	reg, err := c.RegisterClient(ctx, "aws-sso-login", []string{"sso:account:access"})
+	require.NoError(t, err)
+	assert.Equal(t, "client-id", reg.ClientID)
+
+	auth, err := c.StartDeviceAuthorization(ctx, reg.ClientID, reg.ClientSecret, "https://example.awsapps.com/start")
+	require.NoError(t, err)
+	assert.Equal(t, "ABCD-EFGH", auth.UserCode)
+
+	token, err := c.WaitForToken(ctx, reg, auth)
+	require.NoError(t, err)
+	assert.Equal(t, "access-token", token.AccessToken)
+	assert.Equal(t, 3, f.polls)
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, *waits)
+
+	creds, err := c.GetRoleCredentials(ctx, token.AccessToken, "123456789012", "Admin")
+	require.NoError(t, err)
+	assert.Equal(t, "ASIAEXAMPLE", creds.AccessKeyID)
+	assert.Equal(t, int64(1700000000000), creds.Expiration)
+}
+
+func TestGetRoleCredentials_Errors(t *testing.T) {
+	c := newTestClient(t, &fakeSSO{})
+	ctx := context.Background()
+
+	_, err := c.GetRoleCredentials(ctx, "stale-token", "123456789012", "Admin")
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "UnauthorizedException", apiErr.Code)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	_, err = c.GetRoleCredentials(ctx, "access-token", "123456789012", "Other")
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "ForbiddenException", apiErr.Code)
+	assert.Equal(t, "No access", apiErr.Message)
+}
+
+func TestListAccountsAndRoles(t *testing.T) {
+	c := newTestClient(t, &fakeSSO{})
+	ctx := context.Background()
+
+	accounts, err := c.ListAccounts(ctx, "access-token")
+	require.NoError(t, err)
+	assert.Equal(t, []Account{
+		{AccountID: "111111111111", AccountName: "dev"},
+		{AccountID: "222222222222", AccountName: "prod"},
+	}, accounts)
+
+	roles, err := c.ListAccountRoles(ctx, "access-token", "111111111111")
+	require.NoError(t, err)
+	assert.Equal(t, []AccountRole{
+		{AccountID: "111111111111", RoleName: "Admin"},
+		{AccountID: "111111111111", RoleName: "ReadOnly"},
+	}, roles)
+}
+
+func TestWaitForToken_SlowDownAndFailure(t *testing.T) {
+	waits := noSleep(t)
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "slow_down"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "access_denied", "error_description": "denied by user"})
+	}))
+	t.Cleanup(srv.Close)
+	c := &Client{OIDCEndpoint: srv.URL, HTTPClient: srv.Client()}
+
+	_, err := c.WaitForToken(context.Background(), &RegisterClientOutput{}, &DeviceAuthorization{Interval: 1})
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "access_denied", apiErr.Code)
+	assert.Equal(t, []time.Duration{6 * time.Second}, *waits)
+}
+
+func TestNewClient_EndpointOverrides(t *testing.T) {
+	c := NewClient("eu-west-1")
+	assert.Equal(t, "https://oidc.eu-west-1.amazonaws.com", c.OIDCEndpoint)
+	assert.Equal(t, "https://portal.sso.eu-west-1.amazonaws.com", c.PortalEndpoint)
+
+	c = NewClient("cn-north-1")
+	assert.Equal(t, "https://oidc.cn-north-1.amazonaws.com.cn", c.OIDCEndpoint)
+	assert.Equal(t, "https://portal.sso.cn-north-1.amazonaws.com.cn", c.PortalEndpoint)
+
+	t.Setenv("AWS_ENDPOINT_URL_SSO_OIDC", "http://127.0.0.1:8080/")
+	t.Setenv("AWS_ENDPOINT_URL_SSO", "http://127.0.0.1:8081")
+	c = NewClient("eu-west-1")
+	assert.Equal(t, "http://127.0.0.1:8080", c.OIDCEndpoint)
+	assert.Equal(t, "http://127.0.0.1:8081", c.PortalEndpoint)
+}
+
+func TestTokenCache(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "sso", "cache")
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The AWS CLI keys tokens by sso-session name when present, else start URL.
+	assert.Equal(t, "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3", TokenCacheKey("test", "https://example.awsapps.com/start"))
+	assert.Equal(t, "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3", TokenCacheKey("", "test"))
+
+	key := TokenCacheKey("corp", "")
+	tok := &CachedToken{
+		StartURL:              "https://example.awsapps.com/start",
+		Region:                "us-east-1",
+		AccessToken:           "access-token",
+		ExpiresAt:             now.Add(time.Hour).Format(TimeFormat),
+		ClientID:              "client-id",
+		ClientSecret:          "client-secret",
+		RegistrationExpiresAt: now.Add(-time.Hour).Format(TimeFormat),
+	}
+	require.NoError(t, SaveToken(dir, key, tok))
+
+	loaded, err := LoadToken(dir, key)
+	require.NoError(t, err)
+	assert.Equal(t, tok, loaded)
+	assert.True(t, loaded.Valid(now))
+	assert.False(t, loaded.Valid(now.Add(2*time.Hour)))
+	assert.False(t, loaded.RegistrationValid(now))
+
+	// Tokens written by older CLI versions use a "UTC" suffix.
+	loaded.ExpiresAt = "2026-01-01T01:00:00UTC"
+	assert.True(t, loaded.Valid(now))
+}
@@ -0,0 +1,63 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sleepFunc waits for d, or until ctx is done.
+var sleepFunc = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// defaultPollInterval is used when StartDeviceAuthorization returns no interval.
+const defaultPollInterval = 5 * time.Second
+
+// WaitForToken polls CreateToken until the user approves the device
+// authorization, the authorization expires, or ctx is cancelled. It honours
+// the server-provided polling interval and backs off on "slow_down".
+func (c *Client) WaitForToken(ctx context.Context, reg *RegisterClientOutput, auth *DeviceAuthorization) (*Token, error) {
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	var deadline time.Time
+	if auth.ExpiresIn > 0 {
+		deadline = time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+	}
+
+	for {
+		token, err := c.CreateToken(ctx, reg.ClientID, reg.ClientSecret, auth.DeviceCode)
+		if err == nil {
+			return token, nil
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			return nil, err
+		}
+		switch apiErr.Code {
+		case "authorization_pending", "AuthorizationPendingException":
+		case "slow_down", "SlowDownException":
+			interval += 5 * time.Second
+		default:
+			return nil, err
+		}
+
+		if !deadline.IsZero() && time.Now().Add(interval).After(deadline) {
+			return nil, fmt.Errorf("device authorization expired before it was approved")
+		}
+		if err := sleepFunc(ctx, interval); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package sts implements the one AWS STS call the tool needs, AssumeRole,
+// over plain HTTPS with Signature Version 4 signing.
+package sts
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/witnsby/aws-sso-login/src/internal/helper"
+)
+
+// apiVersion is the STS Query API version.
+const apiVersion = "2011-06-15"
+
+// defaultRegion signs requests when no region is configured.
+const defaultRegion = "us-east-1"
+
+// Client calls the STS Query API. Endpoint is a base URL without a trailing
+// slash and may be pointed at a local fake server in tests.
+type Client struct {
+	Endpoint   string
+	Region     string
+	HTTPClient *http.Client
+	// Now returns the signing time; time.Now when nil.
+	Now func() time.Time
+}
+
+// NewClient returns a Client for the regional STS endpoint of region, using
+// the DNS suffix of the region's partition. The endpoint honours the standard
+// AWS_ENDPOINT_URL_STS override.
+func NewClient(region string) *Client {
+	if region == "" {
+		region = defaultRegion
+	}
+	endpoint := fmt.Sprintf("https://sts.%s.%s", region, helper.PartitionForRegion(region).DNSSuffix)
+	if v := os.Getenv("AWS_ENDPOINT_URL_STS"); v != "" {
+		endpoint = v
+	}
+	return &Client{
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		Region:     region,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// APIError is returned for any non-2xx response from STS.
+type APIError struct {
+	StatusCode int
+	Code       string // e.g. "AccessDenied" or "ExpiredToken"
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s (HTTP %d)", e.Code, e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s (HTTP %d)", e.Code, e.Message, e.StatusCode)
+}
+
+// AssumeRoleInput holds the AssumeRole parameters. Empty or zero fields are
+// not sent.
+type AssumeRoleInput struct {
+	RoleARN         string
+	RoleSessionName string
+	ExternalID      string
+	DurationSeconds int
+	SerialNumber    string
+	TokenCode       string
+}
+
+// AssumeRoleOutput holds the temporary credentials and the assumed role identity.
+type AssumeRoleOutput struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"Credentials"`
+	AssumedRoleUser struct {
+		ARN           string `xml:"Arn"`
+		AssumedRoleID string `xml:"AssumedRoleId"`
+	} `xml:"AssumedRoleUser"`
+}
+
+// AssumeRole calls STS AssumeRole, signing the request with creds.
+func (c *Client) AssumeRole(ctx context.Context, creds Credentials, in AssumeRoleInput) (*AssumeRoleOutput, error) {
+	form := url.Values{}
+	form.Set("Action", "AssumeRole")
+	form.Set("Version", apiVersion)
+	form.Set("RoleArn", in.RoleARN)
+	form.Set("RoleSessionName", in.RoleSessionName)
+	if in.ExternalID != "" {
+		form.Set("ExternalId", in.ExternalID)
+	}
+	if in.DurationSeconds > 0 {
+		form.Set("DurationSeconds", strconv.Itoa(in.DurationSeconds))
+	}
+	if in.SerialNumber != "" {
+		form.Set("SerialNumber", in.SerialNumber)
+		form.Set("TokenCode", in.TokenCode)
+	}
+
+	var out struct {
+		Result AssumeRoleOutput `xml:"AssumeRoleResult"`
+	}
+	if err := c.post(ctx, creds, form, &out); err != nil {
+		return nil, err
+	}
+	return &out.Result, nil
+}
+
+// post sends a signed Query API request and decodes the XML response into out,
+// or turns an error response into an *APIError.
+func (c *Client) post(ctx context.Context, creds Credentials, form url.Values, out any) error {
+	body := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	region := c.Region
+	if region == "" {
+		region = defaultRegion
+	}
+	signV4(req, body, creds, region, "sts", now())
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	action := form.Get("Action")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling STS %s: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading STS %s response: %w", action, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return parseAPIError(resp.StatusCode, data)
+	}
+	if err := xml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding STS %s response: %w", action, err)
+	}
+	return nil
+}
+
+// parseAPIError extracts the code and message from an STS ErrorResponse document.
+func parseAPIError(status int, data []byte) *APIError {
+	var body struct {
+		Error struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		} `xml:"Error"`
+	}
+	_ = xml.Unmarshal(data, &body)
+
+	apiErr := &APIError{StatusCode: status, Code: body.Error.Code, Message: body.Error.Message}
+	if apiErr.Code == "" {
+		apiErr.Code = http.StatusText(status)
+	}
+	return apiErr
+}
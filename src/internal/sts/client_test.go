@@ -0,0 +1,129 @@
+package sts
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exampleCreds are the credentials used by the AWS SigV4 test suite.
+var exampleCreds = Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+func TestSignV4_TestSuiteVectors(t *testing.T) {
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	signV4(req, nil, exampleCreds, "us-east-1", "service", now)
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+
+	body := []byte("Param1=value1")
+	req, err = http.NewRequest(http.MethodPost, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	signV4(req, body, exampleCreds, "us-east-1", "service", now)
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		req.Header.Get("Authorization"))
+}
+
+func TestSignV4_SessionToken(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://sts.us-east-1.amazonaws.com/", nil)
+	require.NoError(t, err)
+	creds := exampleCreds
+	creds.SessionToken = "session-token"
+	signV4(req, nil, creds, "us-east-1", "sts", time.Now())
+	assert.Equal(t, "session-token", req.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,")
+}
+
+const assumeRoleResponse = `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::123456789012:assumed-role/Deploy/session</Arn>
+      <AssumedRoleId>AROAEXAMPLE:session</AssumedRoleId>
+    </AssumedRoleUser>
+    <Credentials>
+      <AccessKeyId>ASIACHAINED</AccessKeyId>
+      <SecretAccessKey>chained-secret</SecretAccessKey>
+      <SessionToken>chained-token</SessionToken>
+      <Expiration>2026-10-17T13:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`
+
+func TestAssumeRole(t *testing.T) {
+	var form url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		form, _ = url.ParseQuery(string(body))
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ASIABASE/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/sts/aws4_request")
+		_, _ = io.WriteString(w, assumeRoleResponse)
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_ENDPOINT_URL_STS", srv.URL+"/")
+	c := NewClient("eu-west-1")
+	assert.Equal(t, srv.URL, c.Endpoint)
+
+	out, err := c.AssumeRole(context.Background(),
+		Credentials{AccessKeyID: "ASIABASE", SecretAccessKey: "secret", SessionToken: "session"},
+		AssumeRoleInput{
+			RoleARN:         "arn:aws:iam::123456789012:role/Deploy",
+			RoleSessionName: "session",
+			ExternalID:      "ext",
+			DurationSeconds: 3600,
+			SerialNumber:    "arn:aws:iam::123456789012:mfa/user",
+			TokenCode:       "123456",
+		})
+	require.NoError(t, err)
+	assert.Equal(t, "ASIACHAINED", out.Credentials.AccessKeyID)
+	assert.Equal(t, "chained-token", out.Credentials.SessionToken)
+	assert.Equal(t, time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC), out.Credentials.Expiration)
+	assert.Equal(t, "arn:aws:sts::123456789012:assumed-role/Deploy/session", out.AssumedRoleUser.ARN)
+
+	assert.Equal(t, "AssumeRole", form.Get("Action"))
+	assert.Equal(t, apiVersion, form.Get("Version"))
+	assert.Equal(t, "arn:aws:iam::123456789012:role/Deploy", form.Get("RoleArn"))
+	assert.Equal(t, "ext", form.Get("ExternalId"))
+	assert.Equal(t, "3600", form.Get("DurationSeconds"))
+	assert.Equal(t, "123456", form.Get("TokenCode"))
+}
+
+func TestAssumeRole_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code>`+
+			`<Message>not authorized to perform sts:AssumeRole</Message></Error></ErrorResponse>`)
+	}))
+	defer srv.Close()
+
+	c := &Client{Endpoint: srv.URL, Region: "us-east-1", HTTPClient: srv.Client()}
+	_, err := c.AssumeRole(context.Background(), exampleCreds, AssumeRoleInput{RoleARN: "arn:aws:iam::123456789012:role/Deploy", RoleSessionName: "s"})
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "AccessDenied", apiErr.Code)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	assert.Equal(t, "AccessDenied: not authorized to perform sts:AssumeRole (HTTP 403)", err.Error())
+}
+
+func TestNewClient_Endpoints(t *testing.T) {
+	t.Setenv("AWS_ENDPOINT_URL_STS", "")
+	assert.Equal(t, "https://sts.us-east-1.amazonaws.com", NewClient("").Endpoint)
+	assert.Equal(t, "https://sts.us-gov-west-1.amazonaws.com", NewClient("us-gov-west-1").Endpoint)
+	assert.Equal(t, "https://sts.cn-north-1.amazonaws.com.cn", NewClient("cn-north-1").Endpoint)
+}
@@ -0,0 +1,134 @@
+// Package textdiff renders line-based unified diffs for previewing file edits.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// op is one line of an edit script.
+type op struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// Unified returns a unified diff turning a into b, labelled with the given
+// file names, or "" when they are equal. Line terminators are not compared,
+// so a file only converted between LF and CRLF shows no changes.
+func Unified(nameA, nameB string, a, b []byte) string {
+	ops := diff(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		// Find the next change.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		// Extend the hunk while changes are within 2*contextLines of each other.
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			} else if i-last > 2*contextLines {
+				break
+			}
+		}
+		lo := max(first-contextLines, start)
+		hi := min(last+contextLines+1, len(ops))
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		aStart, bStart := position(ops[:lo])
+		aLen, bLen := position(ops[lo:hi])
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+		for _, o := range ops[lo:hi] {
+			out.WriteByte(o.kind)
+			out.WriteString(o.text)
+			out.WriteByte('\n')
+		}
+		start = hi
+	}
+	return out.String()
+}
+
+// position counts the lines of a and b covered by ops.
+func position(ops []op) (int, int) {
+	na, nb := 0, 0
+	for _, o := range ops {
+		if o.kind != '+' {
+			na++
+		}
+		if o.kind != '-' {
+			nb++
+		}
+	}
+	return na, nb
+}
+
+// hunkRange formats a 0-based start and a length as a unified diff range.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func splitLines(data []byte) []string {
+	s := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diff computes a shortest edit script from a longest common subsequence.
+func diff(a, b []string) []op {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{'+', b[j]})
+	}
+	return ops
+}
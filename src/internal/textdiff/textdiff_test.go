@@ -0,0 +1,38 @@
+package textdiff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnified(t *testing.T) {
+	assert.Equal(t, "", Unified("a", "b", []byte("x\ny\n"), []byte("x\r\ny\r\n")))
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\n3\nfour\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	assert.Equal(t, `--- config
++++ config
+@@ -1,7 +1,7 @@
+ 1
+ 2
+ 3
+-4
++four
+ 5
+ 6
+ 7
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`, Unified("config", "config", []byte(a), []byte(b)))
+
+	assert.Equal(t, `--- old
++++ new
+@@ -0,0 +1,2 @@
++[profile dev]
++sso_session = corp
+`, Unified("old", "new", nil, []byte("[profile dev]\nsso_session = corp\n")))
+}